@@ -3,9 +3,10 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"html/template"
 	"log"
 	"os"
+	"path/filepath"
+	"text/template"
 
 	"github.com/Masterminds/semver"
 	"github.com/clintjedwards/toolkit/config"
@@ -20,7 +21,14 @@ var cmdBuild = &cobra.Command{
 	Long: `Runs the commands under 'build' in config file to build the application
 Injects variables in template format: {{.ExampleVar}}
 
-Variables injected: ProjectName, Path, Version, VersionFull
+Variables injected: ProjectName, ProjectRoot, Path, Version, VersionFull, OS, Arch, Target
+
+ProjectRoot is the directory the config file was found in, which lets build commands
+work when toolkit is run from a subdirectory of the project.
+
+If targets are listed in the config file the build commands are run once per
+target with OS and Arch set (and GOOS/GOARCH in the environment) and the target
+name appended to the path: <path>_<target>
 `,
 	Args: cobra.MinimumNArgs(2),
 	Run:  runBuildCmd,
@@ -28,9 +36,14 @@ Variables injected: ProjectName, Path, Version, VersionFull
 
 type build struct {
 	ProjectName string // the project name grabbed from the repository
+	ProjectRoot string // absolute path of the directory the config file is in
 	Path        string // path where binary will be build
 	Version     string // semver without the v; ex: 1.0.0
 	VersionFull string // ex: <semver>_<epoch>_<commit>
+	OS          string // target operating system; empty if no targets are configured
+	Arch        string // target architecture; empty if no targets are configured
+	Target      string // target name; empty if no targets are configured
+	Targets     []config.Target
 	Commands    map[string][]string
 }
 
@@ -57,36 +70,118 @@ func newBuild(configFile string, args []string) (*build, error) {
 		return nil, fmt.Errorf("could not parse github URL: %w", err)
 	}
 
+	projectRoot, err := filepath.Abs(filepath.Dir(configFile))
+	if err != nil {
+		return nil, fmt.Errorf("could not determine project root: %w", err)
+	}
+
 	return &build{
 		ProjectName: projectName,
+		ProjectRoot: projectRoot,
 		Path:        args[1],
 		Version:     version.String(),
 		VersionFull: versionFull,
+		Targets:     config.Targets,
 		Commands:    config.Commands,
 	}, nil
 }
 
 func runBuildCmd(cmd *cobra.Command, args []string) {
-	configFile, _ := cmd.Flags().GetString("config")
+	_, err := buildTargets(cmd, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// buildTargets runs the build commands for every configured target and returns the
+// resulting artifacts as release assets
+func buildTargets(cmd *cobra.Command, args []string) ([]github.Asset, error) {
+	configFile, err := getConfigPath(cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	newBuild, err := newBuild(configFile, args)
 	if err != nil {
-		log.Fatalf("could not create build instance: %v", err)
+		return nil, fmt.Errorf("could not create build instance: %w", err)
 	}
 
+	var assets []github.Asset
+	for _, targetBuild := range newBuild.forTargets() {
+		err := targetBuild.run(cmd)
+		if err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, targetBuild.asset())
+	}
+
+	return assets, nil
+}
+
+// forTargets returns a copy of the build for each configured target with the target
+// fields and path filled in. If there are no targets the build is returned as is
+func (b *build) forTargets() []*build {
+	if len(b.Targets) == 0 {
+		return []*build{b}
+	}
+
+	var builds []*build
+	for _, target := range b.Targets {
+		targetBuild := *b
+		targetBuild.OS = target.OS
+		targetBuild.Arch = target.Arch
+		targetBuild.Target = target.TargetName()
+		targetBuild.Path = fmt.Sprintf("%s_%s", b.Path, targetBuild.Target)
+		builds = append(builds, &targetBuild)
+	}
+
+	return builds
+}
+
+// asset returns the release asset this build produces
+func (b *build) asset() github.Asset {
+	return github.Asset{
+		Name: github.AssetName(b.ProjectName, b.Target),
+		Path: b.Path,
+	}
+}
+
+// run executes the build command list
+func (b *build) run(cmd *cobra.Command) error {
 	echoCommands, _ := cmd.Flags().GetBool("echoCommands")
+	hideOutput, _ := cmd.Flags().GetBool("hideOutput")
+	dryRun, _ := cmd.Flags().GetBool("dryRun")
+
 	env := os.Environ()
+	if b.OS != "" {
+		env = append(env, "GOOS="+b.OS)
+	}
+	if b.Arch != "" {
+		env = append(env, "GOARCH="+b.Arch)
+	}
 
 	var commandList []string
-	for _, rawCommand := range newBuild.Commands["build"] {
-		command, err := newBuild.substituteTemplate(rawCommand)
+	for _, rawCommand := range b.Commands["build"] {
+		command, err := b.substituteTemplate(rawCommand)
 		if err != nil {
-			log.Fatalf("could not populate command template for command %s; %v", rawCommand, err)
+			return fmt.Errorf("could not populate command template for command %s; %w", rawCommand, err)
 		}
 
 		commandList = append(commandList, command)
 	}
 
+	if dryRun {
+		action := "build"
+		if b.Target != "" {
+			action = "build " + b.Target
+		}
+		for _, command := range commandList {
+			utils.PrintPlan(action, command)
+		}
+		return nil
+	}
+
 	for _, command := range commandList {
 		if echoCommands {
 			fmt.Println("> " + command)
@@ -94,14 +189,15 @@ func runBuildCmd(cmd *cobra.Command, args []string) {
 
 		output, err := utils.ExecuteBashCmd(command, env, "")
 		if err != nil {
-			log.Fatalf("could not run command '%s'; %v", command, err)
+			return fmt.Errorf("could not run command '%s'; %w; %s", command, err, output)
 		}
 
-		hideOutput, _ := cmd.Flags().GetBool("hideOutput")
 		if !hideOutput && len(output) != 0 {
 			fmt.Println(string(output))
 		}
 	}
+
+	return nil
 }
 
 // takes in a command and returns the command with the variables from build struct filled in
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/github"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestForTargets(t *testing.T) {
+	tests := map[string]struct {
+		targets  []config.Target
+		expected []build
+	}{
+		"no targets": {
+			expected: []build{
+				{ProjectName: "toolkit", Path: "/tmp/toolkit"},
+			},
+		},
+		"named and generated targets": {
+			targets: []config.Target{
+				{OS: "linux", Arch: "amd64"},
+				{Name: "mac", OS: "darwin", Arch: "arm64"},
+			},
+			expected: []build{
+				{ProjectName: "toolkit", Path: "/tmp/toolkit_linux_amd64", OS: "linux", Arch: "amd64", Target: "linux_amd64"},
+				{ProjectName: "toolkit", Path: "/tmp/toolkit_mac", OS: "darwin", Arch: "arm64", Target: "mac"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		b := &build{ProjectName: "toolkit", Path: "/tmp/toolkit", Targets: test.targets}
+
+		var builds []build
+		for _, targetBuild := range b.forTargets() {
+			targetBuild.Targets = nil
+			builds = append(builds, *targetBuild)
+		}
+
+		if !cmp.Equal(test.expected, builds) {
+			t.Errorf("%s: builds are not as expected; Diff below: \n%v", name, cmp.Diff(test.expected, builds))
+		}
+	}
+}
+
+func TestBuildAsset(t *testing.T) {
+	tests := map[string]struct {
+		build    build
+		expected github.Asset
+	}{
+		"no target": {
+			build:    build{ProjectName: "toolkit", Path: "/tmp/toolkit"},
+			expected: github.Asset{Name: "toolkit", Path: "/tmp/toolkit"},
+		},
+		"target": {
+			build:    build{ProjectName: "toolkit", Path: "/tmp/toolkit_linux_amd64", Target: "linux_amd64"},
+			expected: github.Asset{Name: "toolkit_linux_amd64", Path: "/tmp/toolkit_linux_amd64"},
+		},
+	}
+
+	for name, test := range tests {
+		asset := test.build.asset()
+		if !cmp.Equal(test.expected, asset) {
+			t.Errorf("%s: asset is not as expected; Diff below: \n%v", name, cmp.Diff(test.expected, asset))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package changelog
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -76,7 +77,8 @@ func getContentsFromUser(filePath string) ([]byte, error) {
 	return changelog, nil
 }
 
-// HandleChangelog opens a pre-populated file for editing and returns the final user contents
+// HandleChangelog opens a file pre-populated with commits since the last tag for editing
+// and returns the final user contents
 func HandleChangelog(name, version, date string, spinner *yacspin.Spinner) ([]byte, error) {
 	spinner.Message("Creating changelog")
 
@@ -97,16 +99,7 @@ func HandleChangelog(name, version, date string, spinner *yacspin.Spinner) ([]by
 		return nil, err
 	}
 
-	tmpl := template.Must(template.New("").Parse(pretext))
-	err = tmpl.Execute(file, struct {
-		Name    string
-		Version string
-		Date    string
-	}{
-		Name:    name,
-		Version: version,
-		Date:    date,
-	})
+	err = writePretext(file, name, version, date)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +113,43 @@ func HandleChangelog(name, version, date string, spinner *yacspin.Spinner) ([]by
 	return getContentsFromUser(filePath)
 }
 
+// PreviewChangelog returns the changelog that HandleChangelog would start the user with
+// without creating any files or opening an editor. A previously recovered changelog is preferred
+func PreviewChangelog(name, version, date string) ([]byte, error) {
+	prefix := "changelog"
+	suffix := "md" // markdown
+	filePath := fmt.Sprintf(filePathFmt, prefix, name, version, suffix)
+
+	contents, err := ioutil.ReadFile(filePath)
+	if err == nil {
+		return removeFileComments(contents), nil
+	}
+
+	var b bytes.Buffer
+	err = writePretext(&b, name, version, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return removeFileComments(b.Bytes()), nil
+}
+
+// writePretext populates the pretext template with commits since the last tag and writes it to w
+func writePretext(w io.Writer, name, version, date string) error {
+	subjects, err := getCommitSubjects()
+	if err != nil {
+		return err
+	}
+
+	tmpl := template.Must(template.New("").Parse(pretext))
+	return tmpl.Execute(w, pretextData{
+		Name:    name,
+		Version: version,
+		Date:    date,
+		Commits: groupCommits(subjects),
+	})
+}
+
 func removeFileComments(data []byte) []byte {
 
 	var newFile [][]byte
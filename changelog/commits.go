@@ -0,0 +1,98 @@
+package changelog
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/clintjedwards/toolkit/utils"
+)
+
+// conventionalCommitRegex matches commit subjects in the form: type(scope)!: description
+var conventionalCommitRegex = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// sections maps conventional commit types to the changelog section they belong in
+var sections = map[string]string{
+	"feat":        "features",
+	"feature":     "features",
+	"perf":        "improvements",
+	"refactor":    "improvements",
+	"improvement": "improvements",
+	"fix":         "bugfixes",
+	"bugfix":      "bugfixes",
+}
+
+// commits contains changelog entries grouped by the section they belong in
+type commits struct {
+	Features     []string
+	Improvements []string
+	BugFixes     []string
+	Other        []string // commits that don't map to a section; shown as comments
+}
+
+// getCommitSubjects returns the subject of every commit since the most recent tag
+// if there are no tags all commits are returned
+func getCommitSubjects() ([]string, error) {
+	env := os.Environ()
+
+	logCmd := "git log --pretty=format:%s"
+
+	// git describe fails when there are no tags, in which case we want the entire history
+	tag, err := utils.ExecuteBashCmd("git describe --tags --abbrev=0", env, "")
+	if err == nil {
+		logCmd = fmt.Sprintf("git log %s..HEAD --pretty=format:%%s", strings.TrimSpace(string(tag)))
+	}
+
+	output, err := utils.ExecuteBashCmd(logCmd, env, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not get commits: %w; %s", err, output)
+	}
+
+	var subjects []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		subjects = append(subjects, line)
+	}
+
+	return subjects, nil
+}
+
+// groupCommits sorts commit subjects into changelog sections based on their conventional commit prefix
+func groupCommits(subjects []string) commits {
+	grouped := commits{}
+
+	for _, subject := range subjects {
+		matches := conventionalCommitRegex.FindStringSubmatch(subject)
+		if matches == nil {
+			grouped.Other = append(grouped.Other, subject)
+			continue
+		}
+
+		commitType, scope, breaking, description := strings.ToLower(matches[1]), matches[2], matches[3], matches[4]
+
+		entry := description
+		if scope != "" {
+			entry = fmt.Sprintf("**%s**: %s", scope, description)
+		}
+		if breaking != "" {
+			entry += " (BREAKING)"
+		}
+
+		switch sections[commitType] {
+		case "features":
+			grouped.Features = append(grouped.Features, entry)
+		case "improvements":
+			grouped.Improvements = append(grouped.Improvements, entry)
+		case "bugfixes":
+			grouped.BugFixes = append(grouped.BugFixes, entry)
+		default:
+			grouped.Other = append(grouped.Other, subject)
+		}
+	}
+
+	return grouped
+}
@@ -0,0 +1,30 @@
+package changelog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGroupCommits(t *testing.T) {
+	subjects := []string{
+		"feat(api): add release listing",
+		"feat!: drop support for old config",
+		"fix: handle empty token file",
+		"perf(build): cache templates",
+		"docs: update readme",
+		"Merge branch 'master'",
+	}
+
+	expected := commits{
+		Features:     []string{"**api**: add release listing", "drop support for old config (BREAKING)"},
+		Improvements: []string{"**build**: cache templates"},
+		BugFixes:     []string{"handle empty token file"},
+		Other:        []string{"docs: update readme", "Merge branch 'master'"},
+	}
+
+	grouped := groupCommits(subjects)
+	if !cmp.Equal(expected, grouped) {
+		t.Errorf("commits not grouped as expected; Diff below: \n%v", cmp.Diff(expected, grouped))
+	}
+}
@@ -0,0 +1,116 @@
+package changelog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/Masterminds/semver"
+)
+
+// entryHeadingRegex matches the heading that starts each release entry. ex: ## v1.0.0 (January 1, 2020)
+var entryHeadingRegex = regexp.MustCompile(`^##\s+v?(\S+)`)
+
+// entry is a single release section of a changelog file
+type entry struct {
+	version *semver.Version // nil if heading does not contain a valid semver
+	text    []byte
+}
+
+// UpdatedChangelogFile returns the contents of the changelog file at path with the changelog for
+// version inserted, without writing it, so a release can be checked against the file before it
+// is published. A file that does not exist is treated as empty. Entries are kept sorted by semver
+// with the newest first and an error is returned if the file already has an entry for the version
+func UpdatedChangelogFile(path, version string, changelog []byte) ([]byte, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return insertEntry(contents, version, changelog)
+}
+
+// insertEntry adds a new release entry into the contents of a changelog file and returns the result
+func insertEntry(contents []byte, version string, changelog []byte) ([]byte, error) {
+	newVersion, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse semver string: %w", err)
+	}
+
+	newText := bytes.TrimSpace(changelog)
+	if !bytes.HasPrefix(newText, []byte("## ")) {
+		newText = append([]byte(fmt.Sprintf("## v%s\n\n", newVersion)), newText...)
+	}
+
+	header, entries := splitEntries(contents)
+
+	for _, e := range entries {
+		if e.version != nil && e.version.Equal(newVersion) {
+			return nil, fmt.Errorf("changelog already contains an entry for v%s", newVersion)
+		}
+	}
+
+	entries = append(entries, entry{version: newVersion, text: newText})
+
+	// entries without a valid version are kept at the bottom in their original order
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[j].version == nil {
+			return entries[i].version != nil
+		}
+		if entries[i].version == nil {
+			return false
+		}
+		return entries[i].version.GreaterThan(entries[j].version)
+	})
+
+	var b bytes.Buffer
+	header = bytes.TrimSpace(header)
+	if len(header) != 0 {
+		b.Write(header)
+		b.WriteString("\n\n")
+	}
+
+	for _, e := range entries {
+		b.Write(bytes.TrimSpace(e.text))
+		b.WriteString("\n\n")
+	}
+
+	return append(bytes.TrimSpace(b.Bytes()), '\n'), nil
+}
+
+// splitEntries breaks a changelog file into any text before the first entry and the entries themselves
+func splitEntries(contents []byte) (header []byte, entries []entry) {
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+
+	var current *entry
+	for _, line := range lines {
+		matches := entryHeadingRegex.FindSubmatch(line)
+		if matches != nil {
+			if current != nil {
+				entries = append(entries, *current)
+			}
+
+			current = &entry{}
+			version, err := semver.NewVersion(string(matches[1]))
+			if err == nil {
+				current.version = version
+			}
+		}
+
+		if current == nil {
+			header = append(header, line...)
+			continue
+		}
+
+		current.text = append(current.text, line...)
+	}
+
+	if current != nil {
+		entries = append(entries, *current)
+	}
+
+	return header, entries
+}
@@ -0,0 +1,51 @@
+package changelog
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInsertEntry(t *testing.T) {
+	contents := []byte(`# Changelog
+
+## v1.2.0 (March 1, 2020)
+
+* newest
+
+## v1.0.0 (January 1, 2020)
+
+* oldest
+`)
+
+	expected := `# Changelog
+
+## v1.2.0 (March 1, 2020)
+
+* newest
+
+## v1.1.0 (February 1, 2020)
+
+FEATURES:
+
+* middle
+
+## v1.0.0 (January 1, 2020)
+
+* oldest
+`
+
+	updated, err := insertEntry(contents, "1.1.0", []byte("\n## v1.1.0 (February 1, 2020)\n\nFEATURES:\n\n* middle\n"))
+	if err != nil {
+		t.Fatalf("could not insert entry: %v", err)
+	}
+
+	if !cmp.Equal(expected, string(updated)) {
+		t.Errorf("changelog does not contain expected output; Diff below: \n%v", cmp.Diff(expected, string(updated)))
+	}
+
+	_, err = insertEntry(updated, "1.1.0", []byte("* duplicate"))
+	if err == nil {
+		t.Errorf("expected error when inserting duplicate version")
+	}
+}
@@ -1,22 +1,48 @@
 package changelog
 
 // pretext is the placeholder text for the input file
+// Sections are pre-populated with conventional commits since the last tag; when a section
+// has no commits an example entry is given instead
 const pretext = `// New release for {{.Name}} v{{.Version}}
 // All lines starting with '//' will be excluded from final changelog
 // Insert changelog below this comment. An example format has been given:
+{{- if .Commits.Other}}
+//
+// The following commits did not match a changelog section:
+{{- range .Commits.Other}}
+// * {{.}}
+{{- end}}
+{{- end}}
 
 ## v{{.Version}} ({{.Date}})
 
 FEATURES:
-
+{{range .Commits.Features}}
+* {{.}}
+{{- else}}
 * **Feature Name**: Description about new feature this release
+{{- end}}
 
 IMPROVEMENTS:
-
+{{range .Commits.Improvements}}
+* {{.}}
+{{- else}}
 * **Improvement Name**: Description about new improvement this release
+{{- end}}
 
 BUG FIXES:
-
+{{range .Commits.BugFixes}}
+* {{.}}
+{{- else}}
 * topic: Description of the bug. Example below [bug#]
 * api: Fix Go API using lease revocation via URL instead of body [GH-7777]
+{{- end}}
 `
+
+// pretextData is the information used to populate the pretext template
+type pretextData struct {
+	Name    string
+	Version string
+	Date    string
+	Commits commits
+}
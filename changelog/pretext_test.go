@@ -7,14 +7,14 @@ import (
 )
 
 func TestPretext(t *testing.T) {
-	project := struct {
-		Name    string
-		Version string
-		Date    string
-	}{
+	project := pretextData{
 		Date:    "Test Date",
 		Name:    "Test",
 		Version: "1.0.0",
+		Commits: commits{
+			Features: []string{"**api**: add release listing"},
+			Other:    []string{"docs: update readme"},
+		},
 	}
 
 	var b bytes.Buffer
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/deploy"
+	"github.com/spf13/cobra"
+)
+
+var cmdConfig = &cobra.Command{
+	Use:   "config",
+	Short: "Commands for working with the toolkit config file",
+}
+
+var cmdConfigValidate = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the config file for mistakes",
+	Long: `Checks the config file for unknown keys, unknown command groups, a malformed
+repository, release archive formats and host key checks toolkit doesn't support and
+command templates that use variables toolkit doesn't provide.
+Every problem found is printed with the line and column it was found at.
+
+Base files named by "extends" are checked along with the config file, and
+references to environment variables in the form ${NAME} or ${NAME:-default} must be
+set or have a default. Use $${NAME} for a literal ${NAME}. Commands and the health
+check command are left as is so they see the variables of the host they run on.
+
+The same checks are run whenever the config file is loaded by other commands.`,
+	Args:          cobra.NoArgs,
+	RunE:          runConfigValidateCmd,
+	SilenceUsage:  true,
+	SilenceErrors: true, // printed by main
+}
+
+func runConfigValidateCmd(cmd *cobra.Command, args []string) error {
+	configFilePath, err := getConfigPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	config := &config.Config{}
+	err = config.Load(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is valid\n", configFilePath)
+	return nil
+}
+
+// structFields returns the names of the exported fields of a struct
+func structFields(v interface{}) []string {
+	var fields []string
+
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			fields = append(fields, t.Field(i).Name)
+		}
+	}
+
+	return fields
+}
+
+func init() {
+	// commands are templates filled in from the build and deploy structs so only their fields can be used
+	config.CommandFields["build"] = structFields(build{})
+	config.CommandFields["deploy"] = structFields(deploy.Deploy{})
+
+	cmdConfig.AddCommand(cmdConfigValidate)
+	rootCmd.AddCommand(cmdConfig)
+}
@@ -1,24 +1,161 @@
 package config
 
 import (
-	"io/ioutil"
-
-	"gopkg.in/yaml.v2"
+	"fmt"
+	"time"
 )
 
 // Config represents per project configuration loaded from the toolkit.yml file
 type Config struct {
-	Repository string `yaml:"repository"` // In form: username/project_name
-	Commands   map[string][]string
+	Extends     string              `yaml:"extends"`     // Optional path of a base config file this one is laid over
+	Repository  string              `yaml:"repository"`  // In form: username/project_name
+	Changelog   string              `yaml:"changelog"`   // Optional path of a changelog file to insert each release into, relative to the config file. ex: CHANGELOG.md
+	Targets     []Target            `yaml:"targets"`     // Optional list of platforms to run the build commands for
+	Release     Release             `yaml:"release"`     // Optional settings for packaging release assets
+	SSH         SSH                 `yaml:"ssh"`         // Optional settings for connecting to deploy hosts
+	Hosts       []string            `yaml:"hosts"`       // Optional hosts deployed to when none are given
+	HostGroups  map[string][]string `yaml:"hostGroups"`  // Optional named lists of hosts to deploy to
+	Healthcheck Healthcheck         `yaml:"healthcheck"` // Optional checks run after the deploy commands
+	Vars        map[string]string   `yaml:"vars"`        // Optional variables available to deploy commands as {{.Vars.name}}
+
+	// Environments are optional named sets of settings that override the ones above when selected
+	Environments map[string]Environment `yaml:"environments"`
+	Commands     map[string][]string
+}
+
+// Environment represents the settings for deploying to a single environment such as staging.
+// Settings that are left out fall back to the top level ones
+type Environment struct {
+	Hosts       []string            `yaml:"hosts"`       // Optional; replaces the top level hosts
+	SSH         *SSH                `yaml:"ssh"`         // Optional; replaces the top level ssh settings
+	Healthcheck *Healthcheck        `yaml:"healthcheck"` // Optional; replaces the top level healthcheck
+	Vars        map[string]string   `yaml:"vars"`        // Optional; merged over the top level vars
+	Commands    map[string][]string `yaml:"commands"`    // Optional; replaces top level command lists of the same name
+}
+
+// Healthcheck represents checks that must pass on a host after the deploy commands complete.
+// Every check that is set must pass; values are filled in using the same template fields as
+// deploy commands
+type Healthcheck struct {
+	HTTP    *HTTPCheck `yaml:"http"`    // Optional http request made from the host over the ssh connection
+	TCP     string     `yaml:"tcp"`     // Optional address the host must be able to connect to. ex: {{.Hostname}}:8080
+	Command string     `yaml:"command"` // Optional command run on the host that must exit 0
+
+	Retries  int           `yaml:"retries"`  // Number of times failing checks are retried; defaults to 5
+	Interval time.Duration `yaml:"interval"` // Time between attempts; defaults to 2s
+	Timeout  time.Duration `yaml:"timeout"`  // Time each check is given per attempt; defaults to 5s
+}
+
+// HTTPCheck represents an http request a healthy service responds to
+type HTTPCheck struct {
+	URL    string `yaml:"url"`
+	Status int    `yaml:"status"` // Expected status code; defaults to 200
+	Body   string `yaml:"body"`   // Optional string the response body must contain
+}
+
+// Enabled returns true if any check is configured
+func (h Healthcheck) Enabled() bool {
+	return h.HTTP != nil || h.TCP != "" || h.Command != ""
+}
+
+// SSH represents settings used when connecting to hosts during a deploy
+type SSH struct {
+	KnownHosts   string `yaml:"knownHosts"`   // Optional known_hosts file checked before ~/.ssh/known_hosts; relative to the config file
+	HostKeyCheck string `yaml:"hostKeyCheck"` // strict or tofu; defaults to tofu
+	IdentityFile string `yaml:"identityFile"` // Optional private key used instead of the defaults in ~/.ssh; relative to the config file
+
+	// JumpHosts are optional bastions connected through in order before reaching the deploy host
+	// in form [user@]host[:port]; overrides ProxyJump from ~/.ssh/config
+	JumpHosts []string `yaml:"jumpHosts"`
+
+	// Hosts contains optional settings for specific hosts keyed by hostname
+	Hosts map[string]SSHHost `yaml:"hosts"`
+}
+
+// SSHHost represents ssh settings that only apply to a single host
+type SSHHost struct {
+	IdentityFile string `yaml:"identityFile"` // Optional private key; overrides the ssh identityFile
+}
+
+// Release represents settings for the assets uploaded with a release
+type Release struct {
+	Assets    []string `yaml:"assets"`    // Optional globs of extra files to upload alongside build artifacts, relative to the config file
+	Archive   string   `yaml:"archive"`   // Optional archive format to package each asset in: tar.gz or zip
+	Checksums bool     `yaml:"checksums"` // Upload a SHA256SUMS file covering all assets
+}
+
+// Target represents a single platform the build commands are run for
+type Target struct {
+	Name string `yaml:"name"` // Optional; defaults to <os>_<arch>
+	OS   string `yaml:"os"`   // injected as GOOS
+	Arch string `yaml:"arch"` // injected as GOARCH
+}
+
+// TargetName returns the name of the target, generating one from the os and arch if not set
+func (t Target) TargetName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+
+	return fmt.Sprintf("%s_%s", t.OS, t.Arch)
+}
+
+// ForEnvironment returns a copy of the config with the settings of the named environment applied
+func (c *Config) ForEnvironment(name string) (*Config, error) {
+	env, ok := c.Environments[name]
+	if !ok {
+		return nil, fmt.Errorf("environment %q not found in config", name)
+	}
+
+	config := *c
+	if len(env.Hosts) != 0 {
+		config.Hosts = env.Hosts
+	}
+	if env.SSH != nil {
+		config.SSH = *env.SSH
+	}
+	if env.Healthcheck != nil {
+		config.Healthcheck = *env.Healthcheck
+	}
+
+	config.Vars = map[string]string{}
+	for key, value := range c.Vars {
+		config.Vars[key] = value
+	}
+	for key, value := range env.Vars {
+		config.Vars[key] = value
+	}
+
+	config.Commands = map[string][]string{}
+	for name, commands := range c.Commands {
+		config.Commands[name] = commands
+	}
+	for name, commands := range env.Commands {
+		config.Commands[name] = commands
+	}
+
+	return &config, nil
 }
 
-// Load reads in a config file and unmarshals it into config struct
+// Load reads in a config file along with any base file it extends, expands environment
+// variables, checks it for mistakes and unmarshals it into config struct.
+// Mistakes such as unknown keys are returned as ValidationErrors
 func (c *Config) Load(filename string) error {
-	f, err := ioutil.ReadFile(filename)
+	doc, err := loadDocument(filename, nil)
 	if err != nil {
 		return err
 	}
 
-	err = yaml.Unmarshal(f, &c)
-	return err
+	errs := append(doc.errs, validate(filename, doc.root, doc.origins)...)
+	if len(errs) != 0 {
+		errs.sort()
+		return errs
+	}
+
+	err = doc.root.Decode(c)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return nil
 }
@@ -42,3 +42,48 @@ commands:
 		t.Errorf("config does not contain expected output; Diff below: \n%v", cmp.Diff(expectedConfig, config))
 	}
 }
+
+func TestForEnvironment(t *testing.T) {
+	config := Config{
+		Hosts: []string{"localhost"},
+		SSH:   SSH{HostKeyCheck: "tofu"},
+		Vars:  map[string]string{"port": "8080", "logLevel": "debug"},
+		Environments: map[string]Environment{
+			"production": {
+				Hosts: []string{"prod-1", "prod-2"},
+				SSH:   &SSH{HostKeyCheck: "strict"},
+				Vars:  map[string]string{"logLevel": "info"},
+				Commands: map[string][]string{
+					"deploy": []string{"systemctl restart app"},
+				},
+			},
+		},
+		Commands: map[string][]string{
+			"build":  []string{"go build"},
+			"deploy": []string{"echo deploy"},
+		},
+	}
+
+	expectedConfig := config
+	expectedConfig.Hosts = []string{"prod-1", "prod-2"}
+	expectedConfig.SSH = SSH{HostKeyCheck: "strict"}
+	expectedConfig.Vars = map[string]string{"port": "8080", "logLevel": "info"}
+	expectedConfig.Commands = map[string][]string{
+		"build":  []string{"go build"},
+		"deploy": []string{"systemctl restart app"},
+	}
+
+	envConfig, err := config.ForEnvironment("production")
+	if err != nil {
+		t.Fatalf("could not apply environment: %v", err)
+	}
+
+	if !cmp.Equal(expectedConfig, *envConfig) {
+		t.Errorf("config does not contain expected output; Diff below: \n%v", cmp.Diff(expectedConfig, *envConfig))
+	}
+
+	_, err = config.ForEnvironment("staging")
+	if err == nil {
+		t.Errorf("expected error for unknown environment")
+	}
+}
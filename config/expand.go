@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variablePattern matches ${NAME} and ${NAME:-default}, along with $${...} which escapes expansion
+var variablePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// shellKeys are keys whose values are shell commands run on the deploy hosts. They are not
+// expanded so references such as ${HOME} see the remote host's environment
+var shellKeys = map[string]bool{
+	"commands": true,
+	"command":  true,
+}
+
+// expandNode replaces environment variable references in every value under the node given.
+// Mapping keys and the values of shellKeys are left as is. A reference to an unset variable
+// without a default is reported
+func expandNode(file string, node *yaml.Node) ValidationErrors {
+	var errs ValidationErrors
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, missing := expand(node.Value)
+		for _, name := range missing {
+			errs = append(errs, ValidationError{
+				File:    file,
+				Line:    node.Line,
+				Column:  node.Column,
+				Message: fmt.Sprintf("environment variable %s is not set; set it or give a default with ${%s:-default}", name, name),
+			})
+		}
+
+		if value != node.Value {
+			node.Value = value
+			// plain values are resolved again so expanded numbers and booleans keep their type
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if shellKeys[node.Content[i-1].Value] {
+				continue
+			}
+			errs = append(errs, expandNode(file, node.Content[i])...)
+		}
+	default:
+		for _, child := range node.Content {
+			errs = append(errs, expandNode(file, child)...)
+		}
+	}
+
+	return errs
+}
+
+// expand replaces environment variable references in a string and returns the names of
+// any that are unset and have no default
+func expand(value string) (string, []string) {
+	var missing []string
+
+	expanded := variablePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if reference[1] == '$' {
+			return reference[1:]
+		}
+
+		match := variablePattern.FindStringSubmatch(reference)
+		name, hasDefault, defaultValue := match[1], match[2] != "", match[3]
+
+		// like the shell, the default is used for variables that are set but empty
+		envValue, ok := os.LookupEnv(name)
+		switch {
+		case envValue != "":
+			return envValue
+		case hasDefault:
+			return defaultValue
+		case ok:
+			return ""
+		default:
+			missing = append(missing, name)
+			return reference
+		}
+	})
+
+	return expanded, missing
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// document is a parsed config file merged with the base files it extends
+type document struct {
+	root    *yaml.Node
+	origins map[*yaml.Node]string // file each node was read from
+	errs    ValidationErrors      // problems found while expanding environment variables
+}
+
+// loadDocument reads a config file, expands environment variables in it and merges it over
+// the file named by its extends key, if any. seen holds the files already loaded so that
+// files extending each other in a loop are reported
+func loadDocument(filename string, seen []string) (*document, error) {
+	absPath, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range seen {
+		if path == absPath {
+			return nil, fmt.Errorf("config files extend each other in a loop: %s",
+				strings.Join(append(seen, absPath), " -> "))
+		}
+	}
+	seen = append(seen, absPath)
+
+	f, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file yaml.Node
+	err = yaml.Unmarshal(f, &file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	doc := &document{
+		root:    &file,
+		origins: map[*yaml.Node]string{},
+	}
+	if len(file.Content) != 0 {
+		doc.root = file.Content[0]
+	}
+
+	recordOrigin(doc.root, filename, doc.origins)
+	doc.errs = expandNode(filename, doc.root)
+
+	extends := mappingValue(doc.root, "extends")
+	if extends == nil || extends.Value == "" {
+		return doc, nil
+	}
+
+	basePath := extends.Value
+	if !filepath.IsAbs(basePath) {
+		basePath = filepath.Join(filepath.Dir(filename), basePath)
+	}
+
+	base, err := loadDocument(basePath, seen)
+	if err != nil {
+		return nil, fmt.Errorf("could not load %s extended by %s: %w", extends.Value, filename, err)
+	}
+
+	for node, origin := range base.origins {
+		doc.origins[node] = origin
+	}
+	doc.errs = append(base.errs, doc.errs...)
+	doc.root = mergeNodes(base.root, doc.root)
+
+	return doc, nil
+}
+
+// mergeNodes returns the result of laying override over base. Mappings are merged key by key
+// and anything else in override replaces what is in base
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	merged := *base
+	merged.Content = append([]*yaml.Node{}, base.Content...)
+
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+
+		found := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j] = key
+				merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+
+	return &merged
+}
+
+// recordOrigin marks every node under the one given as coming from file
+func recordOrigin(node *yaml.Node, file string, origins map[*yaml.Node]string) {
+	origins[node] = file
+	for _, child := range node.Content {
+		recordOrigin(child, file, origins)
+	}
+}
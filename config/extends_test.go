@@ -0,0 +1,89 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadExtends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolkit_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv("TOOLKIT_TEST_DEPLOY_USER", "deployer")
+	defer os.Unsetenv("TOOLKIT_TEST_DEPLOY_USER")
+
+	ioutil.WriteFile(filepath.Join(dir, "base.yml"), []byte(`repository: org/base
+ssh:
+  hostKeyCheck: strict
+  identityFile: ${TOOLKIT_TEST_KEY:-~/.ssh/deploy}
+commands:
+  build:
+    - go build -o {{.Path}}
+  deploy:
+    - systemctl restart app
+`), 0644)
+
+	ioutil.WriteFile(filepath.Join(dir, "service.yml"), []byte(`extends: base.yml
+repository: org/service
+hosts: ["${TOOLKIT_TEST_DEPLOY_USER}@web-1"]
+ssh:
+  hostKeyCheck: tofu
+commands:
+  deploy:
+    - echo ${HOME}
+environments:
+  staging:
+    hosts: ["${TOOLKIT_TEST_DEPLOY_USER}@staging-1"]
+    commands:
+      deploy:
+        - echo ${TOOLKIT_TEST_UNSET}
+`), 0644)
+
+	expectedConfig := Config{
+		Extends:    "base.yml",
+		Repository: "org/service",
+		Hosts:      []string{"deployer@web-1"},
+		SSH:        SSH{HostKeyCheck: "tofu", IdentityFile: "~/.ssh/deploy"},
+		Commands: map[string][]string{
+			"build":  []string{"go build -o {{.Path}}"},
+			"deploy": []string{"echo ${HOME}"},
+		},
+		Environments: map[string]Environment{
+			"staging": {
+				Hosts: []string{"deployer@staging-1"},
+				Commands: map[string][]string{
+					"deploy": []string{"echo ${TOOLKIT_TEST_UNSET}"},
+				},
+			},
+		},
+	}
+
+	config := Config{}
+	err = config.Load(filepath.Join(dir, "service.yml"))
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if !cmp.Equal(expectedConfig, config) {
+		t.Errorf("config does not contain expected output; Diff below: \n%v", cmp.Diff(expectedConfig, config))
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "missing.yml"), []byte(`extends: base.yml
+repository: org/service
+hosts: ["${TOOLKIT_TEST_UNSET}"]
+`), 0644)
+
+	err = config.Load(filepath.Join(dir, "missing.yml"))
+	expectedErr := filepath.Join(dir, "missing.yml") +
+		":3:9: environment variable TOOLKIT_TEST_UNSET is not set; set it or give a default with ${TOOLKIT_TEST_UNSET:-default}"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q; got %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Find looks for a file with the name given in dir and then each of its parents, stopping at the
+// root of the git repository dir is in. Returns the path of the file found
+func Find(dir, name string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	start := dir
+	for {
+		path := filepath.Join(dir, name)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		// .git is a directory in most repositories but a file in worktrees and submodules
+		_, err = os.Stat(filepath.Join(dir, ".git"))
+		if err == nil {
+			return "", fmt.Errorf("could not find %s in %s or its parents up to the git root %s", name, start, dir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("could not find %s in %s or any of its parents", name, start)
+		}
+		dir = parent
+	}
+}
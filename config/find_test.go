@@ -0,0 +1,39 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolkit_find")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a config file above the git root should not be found
+	repo := filepath.Join(dir, "repo")
+	subdir := filepath.Join(repo, "cmd", "app")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	os.MkdirAll(subdir, 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".toolkit.yml"), []byte{}, 0644)
+
+	_, err = Find(subdir, ".toolkit.yml")
+	if err == nil {
+		t.Errorf("expected config file above the git root not to be found")
+	}
+
+	ioutil.WriteFile(filepath.Join(repo, ".toolkit.yml"), []byte{}, 0644)
+
+	path, err := Find(subdir, ".toolkit.yml")
+	if err != nil {
+		t.Fatalf("could not find config file: %v", err)
+	}
+
+	if path != filepath.Join(repo, ".toolkit.yml") {
+		t.Errorf("expected config file in repository root; got %s", path)
+	}
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommandFields lists the command groups a config file may contain along with the template fields
+// available to the commands in each group. A nil list means the fields are not checked.
+// Healthcheck templates are checked against the deploy fields
+var CommandFields = map[string][]string{
+	"build":  nil,
+	"deploy": nil,
+}
+
+var repositoryPattern = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// archiveFormats are the values release.archive may be set to
+var archiveFormats = []string{"tar.gz", "zip"}
+
+// hostKeyChecks are the values ssh.hostKeyCheck may be set to
+var hostKeyChecks = []string{"strict", "tofu"}
+
+// templateErrorPrefix matches the template name and line that template parse errors start with
+var templateErrorPrefix = regexp.MustCompile(`^template: :\d+: `)
+
+// ValidationError is a single problem found in a config file and where it was found
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors is every problem found in a config file
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+type validator struct {
+	file    string
+	origins map[*yaml.Node]string // file each node came from if not file
+	errs    ValidationErrors
+}
+
+// validate checks a parsed config file against the Config struct and returns every problem found
+func validate(file string, root *yaml.Node, origins map[*yaml.Node]string) ValidationErrors {
+	v := &validator{file: file, origins: origins}
+
+	if root.Kind != yaml.MappingNode {
+		v.addf(root, "config must be a mapping of keys to values")
+		return v.errs
+	}
+
+	v.checkKeys(root, reflect.TypeOf(Config{}))
+
+	repository := mappingValue(root, "repository")
+	switch {
+	case repository == nil:
+		v.addf(root, "missing required key \"repository\"")
+	case !repositoryPattern.MatchString(repository.Value):
+		v.addf(repository, "repository %q must be in form username/project_name", repository.Value)
+	}
+
+	v.checkCommands(mappingValue(root, "commands"))
+	v.checkHealthcheck(mappingValue(root, "healthcheck"))
+	v.checkValue(mappingValue(mappingValue(root, "release"), "archive"), "release archive format", archiveFormats)
+	v.checkValue(mappingValue(mappingValue(root, "ssh"), "hostKeyCheck"), "host key check", hostKeyChecks)
+
+	environments := mappingValue(root, "environments")
+	if environments != nil && environments.Kind == yaml.MappingNode {
+		for i := 1; i < len(environments.Content); i += 2 {
+			environment := environments.Content[i]
+			v.checkCommands(mappingValue(environment, "commands"))
+			v.checkHealthcheck(mappingValue(environment, "healthcheck"))
+			v.checkValue(mappingValue(mappingValue(environment, "ssh"), "hostKeyCheck"), "host key check", hostKeyChecks)
+		}
+	}
+
+	v.errs.sort()
+	return v.errs
+}
+
+// sort orders the errors by file and then position
+func (e ValidationErrors) sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		if e[i].File != e[j].File {
+			return e[i].File < e[j].File
+		}
+		if e[i].Line != e[j].Line {
+			return e[i].Line < e[j].Line
+		}
+		return e[i].Column < e[j].Column
+	})
+}
+
+func (v *validator) addf(node *yaml.Node, format string, args ...interface{}) {
+	file, ok := v.origins[node]
+	if !ok {
+		file = v.file
+	}
+
+	v.errs = append(v.errs, ValidationError{
+		File:    file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkKeys reports keys that don't match a field of the type the node is decoded into
+func (v *validator) checkKeys(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				v.addf(key, "unknown key %q", key.Value)
+				continue
+			}
+
+			v.checkKeys(value, field.Type)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+
+		for i := 1; i < len(node.Content); i += 2 {
+			v.checkKeys(node.Content[i], t.Elem())
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+
+		for _, item := range node.Content {
+			v.checkKeys(item, t.Elem())
+		}
+	}
+}
+
+// checkCommands reports unknown command groups and templates that can't be filled in
+func (v *validator) checkCommands(commands *yaml.Node) {
+	if commands == nil || commands.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(commands.Content); i += 2 {
+		group, list := commands.Content[i], commands.Content[i+1]
+		fields, ok := CommandFields[group.Value]
+		if !ok {
+			v.addf(group, "unknown command group %q; expected one of: %s", group.Value, strings.Join(commandGroups(), ", "))
+			continue
+		}
+
+		if list.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, command := range list.Content {
+			v.checkTemplate(command, fields)
+		}
+	}
+}
+
+// checkHealthcheck reports health check templates that can't be filled in
+func (v *validator) checkHealthcheck(healthcheck *yaml.Node) {
+	if healthcheck == nil {
+		return
+	}
+
+	fields := CommandFields["deploy"]
+	for _, key := range []string{"tcp", "command"} {
+		v.checkTemplate(mappingValue(healthcheck, key), fields)
+	}
+	v.checkTemplate(mappingValue(mappingValue(healthcheck, "http"), "url"), fields)
+}
+
+// checkValue reports a value that isn't one of the values given; an empty value is left to the default
+func (v *validator) checkValue(node *yaml.Node, name string, values []string) {
+	if node == nil || node.Kind != yaml.ScalarNode || node.Value == "" {
+		return
+	}
+
+	for _, value := range values {
+		if node.Value == value {
+			return
+		}
+	}
+
+	v.addf(node, "unknown %s %q; expected one of: %s", name, node.Value, strings.Join(values, ", "))
+}
+
+// checkTemplate reports template syntax errors and references to fields not in the list given
+func (v *validator) checkTemplate(node *yaml.Node, fields []string) {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return
+	}
+
+	tmpl, err := template.New("").Parse(node.Value)
+	if err != nil {
+		v.addf(node, "invalid template: %s", templateErrorPrefix.ReplaceAllString(err.Error(), ""))
+		return
+	}
+
+	if fields == nil || tmpl.Tree == nil {
+		return
+	}
+
+	known := map[string]bool{}
+	for _, field := range fields {
+		known[field] = true
+	}
+
+	for _, field := range templateFields(tmpl.Tree.Root) {
+		if !known[field] {
+			v.addf(node, "unknown template field %q; available fields: %s", field, strings.Join(fields, ", "))
+		}
+	}
+}
+
+// templateFields returns the names of the top level fields a template refers to
+func templateFields(node parse.Node) []string {
+	var fields []string
+
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			fields = append(fields, templateFields(child)...)
+		}
+	case *parse.ActionNode:
+		fields = append(fields, templateFields(node.Pipe)...)
+	case *parse.PipeNode:
+		if node == nil {
+			return nil
+		}
+		for _, command := range node.Cmds {
+			fields = append(fields, templateFields(command)...)
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			fields = append(fields, templateFields(arg)...)
+		}
+	case *parse.FieldNode:
+		fields = append(fields, node.Ident[0])
+	case *parse.IfNode:
+		fields = append(fields, branchFields(&node.BranchNode)...)
+	case *parse.RangeNode:
+		fields = append(fields, branchFields(&node.BranchNode)...)
+	case *parse.WithNode:
+		fields = append(fields, branchFields(&node.BranchNode)...)
+	}
+
+	return fields
+}
+
+// branchFields returns the fields referred to by the condition of an if, range or with
+// The contents of range and with blocks are left out since the dot changes inside them
+func branchFields(node *parse.BranchNode) []string {
+	fields := templateFields(node.Pipe)
+	if node.NodeType == parse.NodeIf {
+		fields = append(fields, templateFields(node.List)...)
+		fields = append(fields, templateFields(node.ElseList)...)
+	}
+
+	return fields
+}
+
+// yamlFields returns the fields of a struct keyed by the name they are given in yaml
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fields[name] = field
+	}
+
+	return fields
+}
+
+// mappingValue returns the value of a key in a mapping node or nil if the key is not present
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func commandGroups() []string {
+	groups := []string{}
+	for group := range CommandFields {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	return groups
+}
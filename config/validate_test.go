@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidate(t *testing.T) {
+	commandFields := CommandFields
+	CommandFields = map[string][]string{
+		"build":  []string{"Path", "Version"},
+		"deploy": []string{"Host", "Vars"},
+	}
+	defer func() {
+		CommandFields = commandFields
+	}()
+
+	file := []byte(`repository: clintjedwards
+comands:
+  build:
+    - go build
+commands:
+  build:
+    - go build -o {{.Path}} -ldflags "-X main.version={{.Versoin}}"
+  tset:
+    - go test
+  deploy:
+    - echo {{.Host}} {{.Vars.port}}
+    - echo {{.Host
+healthcheck:
+  tcp: "{{.Hostname}}:8080"
+release:
+  archive: tgz
+ssh:
+  hostKeyCheck: strict
+environments:
+  staging:
+    ssh:
+      hostKeyCheck: stric
+`)
+
+	expectedErrors := ValidationErrors{
+		{File: "test.yml", Line: 1, Column: 13, Message: `repository "clintjedwards" must be in form username/project_name`},
+		{File: "test.yml", Line: 2, Column: 1, Message: `unknown key "comands"`},
+		{File: "test.yml", Line: 7, Column: 7, Message: `unknown template field "Versoin"; available fields: Path, Version`},
+		{File: "test.yml", Line: 8, Column: 3, Message: `unknown command group "tset"; expected one of: build, deploy`},
+		{File: "test.yml", Line: 12, Column: 7, Message: `invalid template: unclosed action`},
+		{File: "test.yml", Line: 14, Column: 8, Message: `unknown template field "Hostname"; available fields: Host, Vars`},
+		{File: "test.yml", Line: 16, Column: 12, Message: `unknown release archive format "tgz"; expected one of: tar.gz, zip`},
+		{File: "test.yml", Line: 22, Column: 21, Message: `unknown host key check "stric"; expected one of: strict, tofu`},
+	}
+
+	var document yaml.Node
+	err := yaml.Unmarshal(file, &document)
+	if err != nil {
+		t.Fatalf("could not parse config: %v", err)
+	}
+
+	errs := validate("test.yml", document.Content[0], nil)
+	if !cmp.Equal(expectedErrors, errs) {
+		t.Errorf("validation errors are not as expected; Diff below: \n%v", cmp.Diff(expectedErrors, errs))
+	}
+}
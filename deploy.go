@@ -1,20 +1,15 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
-	"html/template"
-	"io"
 	"io/ioutil"
-	"log"
-	"net/http"
 	"os"
+	"path/filepath"
 
 	"github.com/Masterminds/semver"
 	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/deploy"
 	"github.com/clintjedwards/toolkit/github"
-	"github.com/clintjedwards/toolkit/sshutil"
-	"github.com/clintjedwards/toolkit/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -22,140 +17,263 @@ import (
 // download the binary from github and then run that binary with appropriate settings
 
 var cmdDeploy = &cobra.Command{
-	Use:   "deploy <semver> <user@host>",
+	Use:   "deploy <semver> [<[user@]host[:port]>...]",
 	Short: "Controls the deployment process for the application",
 	Long: `Downloads version of project specified using github release.
-Then initiates a ssh connection using user host combination and runs commands
-under "deploy" in configuration file`,
-	Args: cobra.MinimumNArgs(2),
-	Run:  runDeployCmd,
+Then initiates a ssh connection to each host, uploads the binary to
+/tmp/<name>_<version> and runs commands under "deploy" in configuration file.
+A local binary can be uploaded instead with --binary, or built from the working tree
+with --build using the commands under "build". A local build is deployed as a dev version
+of the one given, such as 1.2.0-dev+4f2a9c1, so it doesn't replace or get recorded as the
+release on the hosts. The transfer can also be skipped entirely
+with --skipUpload to re-run the deploy commands against a binary already on the host.
+
+The deploy commands run in order in a single shell on the host, so shell state such as
+the working directory carries over between them, and the deploy stops at the first
+command that exits with a non-zero status as it would with "set -e".
+
+Checks under "healthcheck" in the configuration file are retried after the deploy
+commands until they pass; a host whose checks never pass counts as a failed deploy.
+HTTP and TCP checks connect from the host over the ssh connection, so they work for
+hosts behind jump hosts; {{.Hostname}} is the host's real name from ~/.ssh/config.
+
+After a successful deploy the version, time, user and commit are recorded in
+~/.toolkit/<name>/state.json on the host; see "deploy status" and "deploy history".
+If a later deploy fails the deploy commands are run again with the current recorded
+version, whose binary is still at /tmp/<name>_<version>, unless --noRollback is given.
+
+Hosts can be given as arguments, as a named group from "hostGroups" in the
+configuration file using --group, or left out to use "hosts" from the configuration
+file. Multiple hosts are deployed to according to the
+--parallel, --canary and --maxFailures flags.
+
+An environment from "environments" in the configuration file can be selected with
+--env; its hosts, ssh, healthcheck, vars and commands take the place of the top level
+ones. Vars are available to deploy commands and health checks as {{.Vars.<name>}}.
+
+Hosts are resolved using ~/.ssh/config the same way ssh does, so aliases and their
+HostName, User, Port, IdentityFile and ProxyJump settings are honored. Jump hosts
+listed under ssh in the configuration file are used for both the upload and commands.`,
+	Args:          cobra.MinimumNArgs(1),
+	RunE:          runDeployCmd,
+	SilenceUsage:  true,
+	SilenceErrors: true, // printed by main
 }
 
-type deploy struct {
-	Host           string
-	Name           string
-	Version        string
-	DownloadURL    string
-	UploadFilePath string
-	Commands       map[string][]string
-}
-
-func newDeploy(configFile string, args []string) (*deploy, error) {
-	config := &config.Config{}
-	err := config.Load(configFile)
+func runDeployCmd(cmd *cobra.Command, args []string) error {
+	config, projectRoot, err := loadDeployConfig(cmd)
 	if err != nil {
-		return nil, fmt.Errorf("could not load config file: %w", err)
+		return err
 	}
 
-	version, err := semver.NewVersion(args[0])
+	group, _ := cmd.Flags().GetString("group")
+	hosts, err := getDeployHosts(config, group, args[1:])
 	if err != nil {
-		log.Fatalf("could not parse semver string: %v", err)
+		return fmt.Errorf("could not determine hosts to deploy to: %w", err)
 	}
 
-	projectUser, projectName, err := github.ParseGithubURL(config.Repository)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse github URL: %w", err)
-	}
+	target, _ := cmd.Flags().GetString("target")
+	skipChecksum, _ := cmd.Flags().GetBool("skipChecksum")
+	noRollback, _ := cmd.Flags().GetBool("noRollback")
+	skipUpload, _ := cmd.Flags().GetBool("skipUpload")
+	binaryPath, _ := cmd.Flags().GetString("binary")
+	dryRun, _ := cmd.Flags().GetBool("dryRun")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	canary, _ := cmd.Flags().GetBool("canary")
+	maxFailures, _ := cmd.Flags().GetInt("maxFailures")
+
+	version := args[0]
+	commit := ""
+	build, _ := cmd.Flags().GetBool("build")
+	if build {
+		if skipUpload || binaryPath != "" {
+			return fmt.Errorf("--build can't be combined with --binary or --skipUpload")
+		}
 
-	downloadURLFmt := "https://github.com/%s/%s/releases/download/v%s/%s"
-	downloadURL := fmt.Sprintf(downloadURLFmt,
-		projectUser, projectName, version.String(), projectName)
+		buildDir, err := ioutil.TempDir("", "toolkit_build")
+		if err != nil {
+			return fmt.Errorf("could not create build directory: %w", err)
+		}
+		defer os.RemoveAll(buildDir)
 
-	uploadFilePath := fmt.Sprintf("/tmp/%s_%s", projectName, version.String())
+		binaryPath, err = buildBinary(cmd, args[0], target, buildDir)
+		if err != nil {
+			return err
+		}
+		commit = github.GitCommit("HEAD")
 
-	return &deploy{
-		Host:           args[1],
-		Name:           projectName,
-		DownloadURL:    downloadURL,
-		UploadFilePath: uploadFilePath,
-		Version:        version.String(),
-		Commands:       config.Commands,
-	}, nil
-}
+		// the build is not the release, so it gets its own version on the hosts; otherwise it would
+		// replace the release binary and be recorded, and rolled back to, as the release
+		version, err = devVersion(version, commit)
+		if err != nil {
+			return err
+		}
+	}
+
+	results, err := deploy.Run(config, deploy.Options{
+		Version:      version,
+		ProjectRoot:  projectRoot,
+		Hosts:        hosts,
+		Target:       target,
+		SkipChecksum: skipChecksum,
+		SkipUpload:   skipUpload,
+		BinaryPath:   binaryPath,
+		Commit:       commit,
+		NoRollback:   noRollback,
+		DryRun:       dryRun,
+		Strategy: deploy.Strategy{
+			Parallel:    parallel,
+			Canary:      canary,
+			MaxFailures: maxFailures,
+		},
+	})
+
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			fmt.Printf("- %s: skipped\n", result.Host)
+		case result.Err != nil:
+			fmt.Printf("x %v\n", result.Err)
+		default:
+			fmt.Printf("✓ %s\n", result.Host)
+		}
+	}
 
-func runDeployCmd(cmd *cobra.Command, args []string) {
-	configFilePath, _ := cmd.Flags().GetString("config")
+	return err
+}
 
-	newDeploy, err := newDeploy(configFilePath, args)
+// buildBinary runs the build commands for the target being deployed, putting the binary in dir,
+// and returns the path of the binary
+func buildBinary(cmd *cobra.Command, version, target, dir string) (string, error) {
+	configFile, err := getConfigPath(cmd)
 	if err != nil {
-		log.Fatalf("could not create deploy instance: %v", err)
+		return "", err
 	}
 
-	err = newDeploy.transferBinary()
+	newBuild, err := newBuild(configFile, []string{version, ""})
 	if err != nil {
-		log.Fatalf("could not put binary on server: %v", err)
+		return "", fmt.Errorf("could not create build instance: %w", err)
 	}
+	newBuild.Path = filepath.Join(dir, newBuild.ProjectName)
+
+	for _, targetBuild := range newBuild.forTargets() {
+		if targetBuild.Target != target {
+			continue
+		}
 
-	var commandList []string
-	for _, rawCommand := range newDeploy.Commands["deploy"] {
-		command, err := newDeploy.substituteTemplate(rawCommand)
+		err := targetBuild.run(cmd)
 		if err != nil {
-			log.Fatalf("could not populate command template for command %s; %v", rawCommand, err)
+			return "", err
 		}
 
-		commandList = append(commandList, command)
+		return targetBuild.Path, nil
 	}
 
-	sshutil.RunCommandsOverSSH(newDeploy.Host, commandList)
-}
+	if target == "" {
+		return "", fmt.Errorf("build targets are configured; a target to build must be chosen with --target")
+	}
 
-func (d *deploy) transferBinary() error {
+	return "", fmt.Errorf("no build target named %q is configured", target)
+}
 
-	file, err := ioutil.TempFile(os.TempDir(), "*")
+// devVersion returns the version a local build of version is deployed as, marking it as a dev
+// prerelease with the commit it was built from. ex: 1.2.0-dev+4f2a9c1
+func devVersion(version, commit string) (string, error) {
+	parsed, err := semver.NewVersion(version)
 	if err != nil {
-		return fmt.Errorf("could not create tmp file: %w", err)
+		return "", fmt.Errorf("could not parse semver string: %w", err)
 	}
 
-	filename := file.Name()
-	defer os.Remove(filename)
+	prerelease := "dev"
+	if parsed.Prerelease() != "" {
+		prerelease = parsed.Prerelease() + ".dev"
+	}
 
-	log.Println("downloading binary")
-	err = downloadFile(file, d.DownloadURL)
+	dev, err := parsed.SetPrerelease(prerelease)
 	if err != nil {
-		return fmt.Errorf("could not download binary: %w", err)
+		return "", err
 	}
 
-	// Upload the binary we just downloaded to server mentioned
-	uploadCmdFmt := "scp %s %s:%s"
-	uploadCmd := fmt.Sprintf(uploadCmdFmt, filename, d.Host, d.UploadFilePath)
-
-	log.Println("uploading binary")
-	_, err = utils.ExecuteBashCmd(uploadCmd, os.Environ(), "")
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	dev, err = dev.SetMetadata(commit)
 	if err != nil {
-		return fmt.Errorf("could not run command '%s'; %w", uploadCmd, err)
+		return "", err
 	}
 
-	return nil
+	return dev.String(), nil
 }
 
-// downloadFile downloads a file from url and writes it specified file
-func downloadFile(file *os.File, url string) error {
+// loadDeployConfig loads the config file with the environment chosen by --env applied. Also returns
+// the project root, the directory of the config file, which paths in the config file are relative to
+func loadDeployConfig(cmd *cobra.Command) (*config.Config, string, error) {
+	configFilePath, err := getConfigPath(cmd)
+	if err != nil {
+		return nil, "", err
+	}
 
-	resp, err := http.Get(url)
+	projectRoot, err := filepath.Abs(filepath.Dir(configFilePath))
 	if err != nil {
-		return err
+		return nil, "", fmt.Errorf("could not determine project root: %w", err)
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(file, resp.Body)
-	return err
+	deployConfig := &config.Config{}
+	err = deployConfig.Load(configFilePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not load config file: %w", err)
+	}
+
+	env, _ := cmd.Flags().GetString("env")
+	if env == "" {
+		return deployConfig, projectRoot, nil
+	}
+
+	deployConfig, err = deployConfig.ForEnvironment(env)
+	return deployConfig, projectRoot, err
 }
 
-// takes in a command and returns the command with the variables from build struct filled in
-func (d *deploy) substituteTemplate(command string) (string, error) {
+// getDeployHosts returns the hosts given as arguments, the hosts in the named group or
+// the hosts listed in the config file, in that order of preference
+func getDeployHosts(config *config.Config, group string, args []string) ([]string, error) {
+	if group != "" && len(args) != 0 {
+		return nil, fmt.Errorf("hosts cannot be given as arguments when using --group")
+	}
 
-	cmdBuffer := bytes.NewBuffer([]byte{})
+	if group == "" {
+		if len(args) != 0 {
+			return args, nil
+		}
+		if len(config.Hosts) == 0 {
+			return nil, fmt.Errorf("no hosts given; pass hosts as arguments, use --group or list hosts in the config file")
+		}
+		return config.Hosts, nil
+	}
 
-	tmpl := template.Must(template.New("").Parse(command))
-	err := tmpl.Execute(cmdBuffer, d)
-	if err != nil {
-		return "", err
+	hosts, ok := config.HostGroups[group]
+	if !ok {
+		return nil, fmt.Errorf("host group %q not found in config", group)
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("host group %q has no hosts", group)
 	}
 
-	return string(cmdBuffer.String()), nil
+	return hosts, nil
 }
 
 func init() {
+	cmdDeploy.PersistentFlags().StringP("env", "e", "", "name of an environment from the config file to deploy to")
+	cmdDeploy.Flags().String("target", "", "name of the build target to deploy; required with targets configured unless --binary or --skipUpload is given")
+	cmdDeploy.Flags().Bool("skipChecksum", false, "don't verify the downloaded binary against the release SHA256SUMS file when release checksums are enabled")
+	cmdDeploy.Flags().Bool("skipUpload", false, "don't download or upload the binary; it must already be on each host from an earlier deploy")
+	cmdDeploy.Flags().String("binary", "", "path of a local binary to upload instead of downloading the release")
+	cmdDeploy.Flags().Bool("build", false, "run the build commands and upload the result instead of downloading the release")
+	cmdDeploy.Flags().Bool("noRollback", false, "don't roll a host back to its previous version if the deploy fails")
+	cmdDeploy.Flags().StringP("group", "g", "", "name of a host group from the config file to deploy to")
+	cmdDeploy.Flags().IntP("parallel", "p", 1, "number of hosts to deploy to at the same time")
+	cmdDeploy.Flags().Bool("canary", false, "deploy to the first host on its own and halt if it fails")
+	cmdDeploy.Flags().Int("maxFailures", 0, "number of failed hosts tolerated before the rollout is halted")
 
 	rootCmd.AddCommand(cmdDeploy)
 }
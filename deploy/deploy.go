@@ -0,0 +1,591 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/semver"
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/github"
+	"github.com/clintjedwards/toolkit/sshutil"
+	"github.com/clintjedwards/toolkit/utils"
+	"github.com/mitchellh/go-homedir"
+)
+
+// Deploy is a deploy of a version of the project to a single host. Its fields are available
+// to the deploy commands and health checks as template variables
+type Deploy struct {
+	Host           string
+	Hostname       string // real name of the host from ~/.ssh/config, without the user or port
+	Name           string
+	Version        string
+	AssetName      string // name of the release asset to download
+	DownloadURL    string
+	ChecksumURL    string // url of the release checksums file; empty skips verification
+	UploadFilePath string
+	SSH            sshutil.Options
+	Archive        string // archive format the release asset is packaged in; empty if not archived
+	Commands       map[string][]string
+	Healthcheck    config.Healthcheck
+	Vars           map[string]string // extra variables from the config file
+	Commit         string            // commit the binary was built from; recorded in the host's deploy history
+	NoRollback     bool              // leave the host as is if the deploy fails instead of going back to the previous version
+}
+
+// Options describes a deploy independently of the command line so other programs can
+// deploy with Run
+type Options struct {
+	Version      string
+	ProjectRoot  string // directory relative paths in the config file are resolved against; defaults to the working directory
+	Hosts        []string
+	Target       string // name of the build target to deploy; required to download a release if targets are configured
+	SkipChecksum bool
+	SkipUpload   bool   // the binary is already on the hosts at /tmp/<name>_<version>
+	BinaryPath   string // optional local binary uploaded instead of downloading the release
+	Commit       string // optional commit recorded in the host's history; defaults to the release tag's commit
+	NoRollback   bool
+	DryRun       bool
+	Strategy     Strategy
+	Stdout       io.Writer // defaults to os.Stdout
+	Stderr       io.Writer // defaults to os.Stderr
+}
+
+// Error records the host and stage a deploy failed at
+type Error struct {
+	Host  string
+	Stage string // one of the Stage constants
+	Err   error
+
+	RolledBackTo string // version the host was rolled back to; empty if no rollback was attempted
+	RollbackErr  error  // set if the rollback was attempted and failed
+}
+
+// Stages of a deploy to a host, in the order they run
+const (
+	StagePrepare     = "prepare"
+	StageConnect     = "connect"
+	StageUpload      = "upload"
+	StageCommands    = "commands"
+	StageHealthcheck = "healthcheck"
+	StageRecord      = "record"
+)
+
+func (e *Error) Error() string {
+	switch {
+	case e.RolledBackTo != "" && e.RollbackErr != nil:
+		return fmt.Sprintf("%s: %s failed (rollback to %s also failed: %v): %v",
+			e.Host, e.Stage, e.RolledBackTo, e.RollbackErr, e.Err)
+	case e.RolledBackTo != "":
+		return fmt.Sprintf("%s: %s failed (rolled back to %s): %v", e.Host, e.Stage, e.RolledBackTo, e.Err)
+	default:
+		return fmt.Sprintf("%s: %s failed: %v", e.Host, e.Stage, e.Err)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// RolloutError is returned when a deploy did not complete on every host
+type RolloutError struct {
+	Results []HostResult
+}
+
+func (e *RolloutError) Error() string {
+	return fmt.Sprintf("deploy did not complete on %d of %d hosts", len(e.Failed()), len(e.Results))
+}
+
+// Failed returns the results of the hosts that failed or were skipped
+func (e *RolloutError) Failed() []HostResult {
+	failed := []HostResult{}
+	for _, result := range e.Results {
+		if result.Skipped || result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+func newDeploy(config *config.Config, projectRoot, target, rawVersion, host string) (*Deploy, error) {
+	version, err := semver.NewVersion(rawVersion)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse semver string: %w", err)
+	}
+
+	projectUser, projectName, err := github.ParseGithubURL(config.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse github URL: %w", err)
+	}
+
+	asset := github.ArchiveName(github.AssetName(projectName, target), config.Release.Archive)
+
+	downloadURLFmt := "https://github.com/%s/%s/releases/download/v%s/%s"
+	downloadURL := fmt.Sprintf(downloadURLFmt,
+		projectUser, projectName, version.String(), asset)
+
+	// releases only include a checksums file if the project is configured to upload one
+	checksumURL := ""
+	if config.Release.Checksums {
+		checksumURL = fmt.Sprintf(downloadURLFmt,
+			projectUser, projectName, version.String(), github.ChecksumsFileName)
+	}
+
+	uploadFilePath := fmt.Sprintf("/tmp/%s_%s", projectName, version.String())
+
+	sshOptions, err := SSHOptions(config.SSH, projectRoot, host)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := sshutil.ResolveHostname(host, sshOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Deploy{
+		Host:           host,
+		Hostname:       hostname,
+		Name:           projectName,
+		AssetName:      asset,
+		DownloadURL:    downloadURL,
+		ChecksumURL:    checksumURL,
+		UploadFilePath: uploadFilePath,
+		Version:        version.String(),
+		SSH:            sshOptions,
+		Archive:        config.Release.Archive,
+		Commands:       config.Commands,
+		Healthcheck:    config.Healthcheck,
+		Vars:           config.Vars,
+	}, nil
+}
+
+// Run deploys the version given to every host in opts and returns the result for each
+// host. If the deploy did not complete on every host the error is a *RolloutError.
+// No results are returned for a dry run.
+func Run(config *config.Config, opts Options) ([]HostResult, error) {
+	if len(opts.Hosts) == 0 {
+		return nil, fmt.Errorf("no hosts to deploy to")
+	}
+
+	if opts.SkipUpload && opts.BinaryPath != "" {
+		return nil, fmt.Errorf("a binary can't be given when skipping the upload")
+	}
+
+	// the target only picks which release asset to download
+	download := opts.BinaryPath == "" && !opts.SkipUpload
+	if download && len(config.Targets) != 0 && opts.Target == "" {
+		return nil, fmt.Errorf("build targets are configured; a target must be chosen with --target")
+	}
+
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	commit := opts.Commit
+	if commit == "" {
+		commit = github.GitCommit("v" + opts.Version)
+	}
+
+	deploys := map[string]*Deploy{}
+	for _, host := range opts.Hosts {
+		newDeploy, err := newDeploy(config, opts.ProjectRoot, opts.Target, opts.Version, host)
+		if err != nil {
+			return nil, fmt.Errorf("could not create deploy instance: %w", err)
+		}
+
+		if opts.SkipChecksum {
+			newDeploy.ChecksumURL = ""
+		}
+		newDeploy.NoRollback = opts.NoRollback
+		newDeploy.Commit = commit
+
+		deploys[host] = newDeploy
+	}
+
+	// every host gets the same binary so the release asset only differs by host in name
+	first := deploys[opts.Hosts[0]]
+
+	if opts.DryRun {
+		source := opts.BinaryPath
+		if download {
+			first.planFetchBinary()
+			source = "<downloaded file>"
+		}
+
+		for _, host := range opts.Hosts {
+			err := deploys[host].planDeploy(source)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	binaryPath := opts.BinaryPath
+	if download {
+		var err error
+		binaryPath, err = first.fetchBinary(stdout)
+		if err != nil {
+			return nil, fmt.Errorf("could not get binary: %w", err)
+		}
+		defer os.Remove(binaryPath)
+	}
+
+	results := rollout(opts.Hosts, opts.Strategy, func(host string) error {
+		if len(opts.Hosts) == 1 {
+			return deploys[host].deployTo(binaryPath, stdout, stderr, stdout)
+		}
+
+		// prefix output with the host so output from hosts deployed in parallel can be told apart
+		hostStdout := utils.NewPrefixWriter(stdout, fmt.Sprintf("[%s] ", host))
+		hostStderr := utils.NewPrefixWriter(stderr, fmt.Sprintf("[%s] ", host))
+		defer hostStdout.Flush()
+		defer hostStderr.Flush()
+
+		return deploys[host].deployTo(binaryPath, hostStdout, hostStderr, nil)
+	})
+
+	for _, result := range results {
+		if result.Skipped || result.Err != nil {
+			return results, &RolloutError{Results: results}
+		}
+	}
+
+	return results, nil
+}
+
+// commandList returns the deploy commands with the variables from the deploy struct filled in
+func (d *Deploy) commandList() ([]string, error) {
+	var commandList []string
+	for _, rawCommand := range d.Commands["deploy"] {
+		command, err := d.substituteTemplate(rawCommand)
+		if err != nil {
+			return nil, fmt.Errorf("could not populate command template for command %s; %w", rawCommand, err)
+		}
+
+		commandList = append(commandList, command)
+	}
+
+	return commandList, nil
+}
+
+// deployTo uploads the binary to the host and runs the deploy commands over a single connection
+// remote command output is written to stdout and stderr, upload progress to progress if not nil
+// An empty binaryPath skips the upload and uses the binary already on the host
+func (d *Deploy) deployTo(binaryPath string, stdout, stderr, progress io.Writer) error {
+	commandList, err := d.commandList()
+	if err != nil {
+		return &Error{Host: d.Host, Stage: StagePrepare, Err: err}
+	}
+
+	client, err := sshutil.Dial(d.Host, d.SSH)
+	if err != nil {
+		return &Error{Host: d.Host, Stage: StageConnect, Err: err}
+	}
+	defer client.Close()
+
+	client.Stdout = stdout
+	client.Stderr = stderr
+
+	previousVersion, err := d.previousVersion(client)
+	if err != nil {
+		return &Error{Host: d.Host, Stage: StagePrepare, Err: err}
+	}
+
+	if binaryPath == "" {
+		err = d.checkBinary(client)
+	} else {
+		err = d.transferBinary(client, binaryPath, progress)
+	}
+	if err != nil {
+		return &Error{Host: d.Host, Stage: StageUpload, Err: err}
+	}
+
+	err = client.RunCommands(commandList)
+	if err != nil {
+		return d.failed(client, StageCommands, err, previousVersion)
+	}
+
+	err = d.runHealthchecks(client)
+	if err != nil {
+		return d.failed(client, StageHealthcheck, err, previousVersion)
+	}
+
+	err = d.recordDeployment(client)
+	if err != nil {
+		return &Error{Host: d.Host, Stage: StageRecord, Err: err}
+	}
+
+	return nil
+}
+
+// failed returns the error for a deploy that failed after the host was changed, rolling the host
+// back to the previous version first if there is one to go back to
+func (d *Deploy) failed(client *sshutil.Client, stage string, err error, previousVersion string) error {
+	deployErr := &Error{Host: d.Host, Stage: stage, Err: err}
+	if d.NoRollback || previousVersion == "" || previousVersion == d.Version {
+		return deployErr
+	}
+
+	deployErr.RolledBackTo = previousVersion
+	deployErr.RollbackErr = d.rollback(client, previousVersion)
+	return deployErr
+}
+
+// SSHOptions converts ssh settings from the config file into connection options for the host given
+// the known hosts file from the config is checked first, followed by ~/.ssh/known_hosts. Relative
+// paths are resolved against the project root
+func SSHOptions(settings config.SSH, projectRoot, host string) (sshutil.Options, error) {
+	var knownHostsFiles []string
+
+	if settings.KnownHosts != "" {
+		path, err := projectPath(projectRoot, settings.KnownHosts)
+		if err != nil {
+			return sshutil.Options{}, fmt.Errorf("could not expand known hosts path: %w", err)
+		}
+		knownHostsFiles = append(knownHostsFiles, path)
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return sshutil.Options{}, fmt.Errorf("could not get user home dir: %w", err)
+	}
+	knownHostsFiles = append(knownHostsFiles, filepath.Join(home, ".ssh", "known_hosts"))
+
+	identityFile := settings.IdentityFile
+
+	// per host settings are keyed by hostname without the user or port
+	_, hostname, _ := sshutil.SplitDestination(host)
+	if hostSettings, ok := settings.Hosts[hostname]; ok && hostSettings.IdentityFile != "" {
+		identityFile = hostSettings.IdentityFile
+	}
+
+	if identityFile != "" {
+		identityFile, err = projectPath(projectRoot, identityFile)
+		if err != nil {
+			return sshutil.Options{}, fmt.Errorf("could not expand identity file path: %w", err)
+		}
+	}
+
+	return sshutil.Options{
+		KnownHostsFiles: knownHostsFiles,
+		HostKeyCheck:    settings.HostKeyCheck,
+		IdentityFile:    identityFile,
+		JumpHosts:       settings.JumpHosts,
+	}, nil
+}
+
+// projectPath expands ~ in a path from the config file and joins it to the project root if it is
+// still relative
+func projectPath(projectRoot, path string) (string, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.IsAbs(path) || projectRoot == "" {
+		return path, nil
+	}
+
+	return filepath.Join(projectRoot, path), nil
+}
+
+// planFetchBinary prints the steps fetchBinary would take without running them
+func (d *Deploy) planFetchBinary() {
+	utils.PrintPlan("download", d.DownloadURL)
+	if d.ChecksumURL != "" {
+		utils.PrintPlan("verify", fmt.Sprintf("sha256 of %s against %s", d.AssetName, d.ChecksumURL))
+	}
+	if d.Archive != "" {
+		utils.PrintPlan("extract", fmt.Sprintf("binary from %s archive", d.Archive))
+	}
+}
+
+// planDeploy prints the steps deployTo would take without running them. The binary uploaded is
+// described by source; an empty source means the binary is already on the host
+func (d *Deploy) planDeploy(source string) error {
+	commandList, err := d.commandList()
+	if err != nil {
+		return err
+	}
+
+	if len(d.SSH.JumpHosts) != 0 {
+		utils.PrintPlan("connect", fmt.Sprintf("%s via %s", d.Host, strings.Join(d.SSH.JumpHosts, ",")))
+	}
+	if source == "" {
+		utils.PrintPlan("ssh "+d.Host, fmt.Sprintf("check %s exists", d.UploadFilePath))
+	} else {
+		utils.PrintPlan("upload", fmt.Sprintf("%s to %s:%s", source, d.Host, d.UploadFilePath))
+	}
+	for _, command := range commandList {
+		utils.PrintPlan("ssh "+d.Host, command)
+	}
+	err = d.planHealthchecks()
+	if err != nil {
+		return err
+	}
+	utils.PrintPlan("ssh "+d.Host, fmt.Sprintf("record deployment of %s in %s", d.Version, stateFile(d.Name)))
+	if !d.NoRollback {
+		utils.PrintPlan("rollback", fmt.Sprintf("on failure re-run deploy commands on %s with the current version in %s", d.Host, stateFile(d.Name)))
+	}
+
+	return nil
+}
+
+// fetchBinary downloads the release binary, verifies its checksum and extracts it if needed,
+// writing progress to log. Returns the local path of the binary; caller should remember to remove it
+func (d *Deploy) fetchBinary(log io.Writer) (string, error) {
+
+	file, err := ioutil.TempFile(os.TempDir(), "*")
+	if err != nil {
+		return "", fmt.Errorf("could not create tmp file: %w", err)
+	}
+
+	defer file.Close()
+
+	filename := file.Name()
+
+	fmt.Fprintln(log, "downloading binary")
+	err = downloadFile(file, d.DownloadURL)
+	if err != nil {
+		os.Remove(filename)
+		return "", fmt.Errorf("could not download binary: %w", err)
+	}
+
+	if d.ChecksumURL != "" {
+		fmt.Fprintln(log, "verifying binary checksum")
+		err = d.verifyChecksum(filename)
+		if err != nil {
+			os.Remove(filename)
+			return "", fmt.Errorf("could not verify binary: %w", err)
+		}
+	}
+
+	if d.Archive != "" {
+		extracted, err := extractBinary(filename, d.Archive, log)
+		os.Remove(filename)
+		if err != nil {
+			return "", fmt.Errorf("could not extract binary: %w", err)
+		}
+
+		filename = extracted
+	}
+
+	// temp files are created without execute permissions and the upload preserves file mode
+	err = os.Chmod(filename, 0755)
+	if err != nil {
+		os.Remove(filename)
+		return "", fmt.Errorf("could not set binary permissions: %w", err)
+	}
+
+	return filename, nil
+}
+
+// checkBinary makes sure the binary is already on the host when the upload is skipped
+func (d *Deploy) checkBinary(client *sshutil.Client) error {
+	_, err := client.Output(fmt.Sprintf("test -f %s", d.UploadFilePath))
+	if err != nil {
+		return fmt.Errorf("upload skipped but binary is not at %s:%s", d.Host, d.UploadFilePath)
+	}
+
+	return nil
+}
+
+// transferBinary uploads the local binary to the server over the client given
+func (d *Deploy) transferBinary(client *sshutil.Client, binaryPath string, progress io.Writer) error {
+	fmt.Fprintln(client.Stdout, "uploading binary")
+	err := client.UploadFile(binaryPath, d.UploadFilePath, progress)
+	if err != nil {
+		return fmt.Errorf("could not upload binary to %s:%s; %w", d.Host, d.UploadFilePath, err)
+	}
+
+	return nil
+}
+
+// extractBinary extracts the binary from a downloaded release archive into a new temp file
+// and returns the path of that file
+func extractBinary(archivePath, format string, log io.Writer) (string, error) {
+	file, err := ioutil.TempFile(os.TempDir(), "*")
+	if err != nil {
+		return "", fmt.Errorf("could not create tmp file: %w", err)
+	}
+	defer file.Close()
+
+	fmt.Fprintln(log, "extracting binary")
+	err = github.ExtractArchive(archivePath, format, file)
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// verifyChecksum compares the sha256 sum of the downloaded file against the one
+// listed for the asset in the release checksums file
+func (d *Deploy) verifyChecksum(filename string) error {
+	var checksums bytes.Buffer
+	err := downloadFile(&checksums, d.ChecksumURL)
+	if err != nil {
+		return fmt.Errorf("could not download checksums file (use --skipChecksum for releases without one): %w", err)
+	}
+
+	expected, ok := github.ParseChecksums(checksums.Bytes())[d.AssetName]
+	if !ok {
+		return fmt.Errorf("checksums file %s has no entry for %s", d.ChecksumURL, d.AssetName)
+	}
+
+	actual, err := github.FileChecksum(filename)
+	if err != nil {
+		return fmt.Errorf("could not compute checksum: %w", err)
+	}
+
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s; expected %s got %s", d.AssetName, expected, actual)
+	}
+
+	return nil
+}
+
+// downloadFile downloads a file from url and writes it to the writer specified
+// returns an error if the server does not respond with 200 OK
+func downloadFile(w io.Writer, url string) error {
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not download %s: server responded with %s", url, resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// takes in a command and returns the command with the variables from build struct filled in
+func (d *Deploy) substituteTemplate(command string) (string, error) {
+
+	cmdBuffer := bytes.NewBuffer([]byte{})
+
+	tmpl := template.Must(template.New("").Parse(command))
+	err := tmpl.Execute(cmdBuffer, d)
+	if err != nil {
+		return "", err
+	}
+
+	return string(cmdBuffer.String()), nil
+}
@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"testing"
+
+	"github.com/clintjedwards/toolkit/config"
+)
+
+func TestRunTargetRequired(t *testing.T) {
+	targetConfig := &config.Config{
+		Repository: "clintjedwards/toolkit",
+		Targets:    []config.Target{{OS: "linux", Arch: "amd64"}},
+	}
+
+	tests := map[string]struct {
+		opts     Options
+		required bool
+	}{
+		"download without target": {
+			opts:     Options{},
+			required: true,
+		},
+		"download with target": {
+			opts: Options{Target: "linux_amd64"},
+		},
+		"local binary": {
+			opts: Options{BinaryPath: "/tmp/toolkit"},
+		},
+		"skip upload": {
+			opts: Options{SkipUpload: true},
+		},
+	}
+
+	for name, test := range tests {
+		opts := test.opts
+		opts.Version = "1.0.0"
+		opts.Hosts = []string{"web-1"}
+		opts.DryRun = true
+
+		_, err := Run(targetConfig, opts)
+		if test.required && err == nil {
+			t.Errorf("%s: expected an error for the missing target", name)
+		}
+		if !test.required && err != nil {
+			t.Errorf("%s: expected no error; got %v", name, err)
+		}
+	}
+}
+
+func TestSSHOptionsHostSettings(t *testing.T) {
+	settings := config.SSH{
+		IdentityFile: "/keys/default",
+		Hosts:        map[string]config.SSHHost{"web-1": {IdentityFile: "/keys/web-1"}},
+	}
+
+	tests := map[string]string{
+		"web-1":             "/keys/web-1",
+		"deploy@web-1":      "/keys/web-1",
+		"deploy@web-1:2222": "/keys/web-1",
+		"deploy@web-2:2222": "/keys/default",
+		"[::1]:2222":        "/keys/default",
+	}
+
+	for host, expected := range tests {
+		opts, err := SSHOptions(settings, "/project", host)
+		if err != nil {
+			t.Errorf("%s: could not create ssh options: %v", host, err)
+			continue
+		}
+		if opts.IdentityFile != expected {
+			t.Errorf("%s: expected identity file %s; got %s", host, expected, opts.IdentityFile)
+		}
+	}
+}
+
+func TestSSHOptionsProjectPaths(t *testing.T) {
+	settings := config.SSH{
+		KnownHosts:   "deploy/known_hosts",
+		IdentityFile: "keys/default",
+		Hosts:        map[string]config.SSHHost{"web-2": {IdentityFile: "/keys/web-2"}},
+	}
+
+	tests := map[string]string{
+		"web-1": "/project/keys/default",
+		"web-2": "/keys/web-2",
+	}
+
+	for host, expected := range tests {
+		opts, err := SSHOptions(settings, "/project", host)
+		if err != nil {
+			t.Errorf("%s: could not create ssh options: %v", host, err)
+			continue
+		}
+		if opts.IdentityFile != expected {
+			t.Errorf("%s: expected identity file %s; got %s", host, expected, opts.IdentityFile)
+		}
+		if opts.KnownHostsFiles[0] != "/project/deploy/known_hosts" {
+			t.Errorf("%s: expected known hosts file /project/deploy/known_hosts; got %s", host, opts.KnownHostsFiles[0])
+		}
+	}
+}
+
+func TestSubstituteTemplate(t *testing.T) {
+	d := &Deploy{Host: "web-1", Vars: map[string]string{"flags": "--name='web' --a=1&b=2"}}
+
+	// commands are shell, not html, so values must come through unescaped
+	command, err := d.substituteTemplate("app {{.Vars.flags}} > /tmp/{{.Host}}.log")
+	if err != nil {
+		t.Fatalf("could not populate template: %v", err)
+	}
+
+	expected := "app --name='web' --a=1&b=2 > /tmp/web-1.log"
+	if command != expected {
+		t.Errorf("expected command %q; got %q", expected, command)
+	}
+}
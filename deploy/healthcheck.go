@@ -0,0 +1,216 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/sshutil"
+	"github.com/clintjedwards/toolkit/utils"
+)
+
+const (
+	defaultHealthcheckRetries  = 5
+	defaultHealthcheckInterval = 2 * time.Second
+	defaultHealthcheckTimeout  = 5 * time.Second
+)
+
+// healthcheck is a single populated check that can be attempted repeatedly
+type healthcheck struct {
+	Name  string
+	Check func(timeout time.Duration) error
+}
+
+// dialFunc opens a connection to an address, such as net.DialTimeout or sshutil.Client.DialTimeout
+type dialFunc func(network, address string, timeout time.Duration) (net.Conn, error)
+
+// healthchecks returns the configured checks with their template fields populated. Checks
+// connect from the host over the client given, so services that only listen on the host's
+// private network or are behind jump hosts can be checked
+func (d *Deploy) healthchecks(client *sshutil.Client) ([]healthcheck, error) {
+	var checks []healthcheck
+	settings := d.Healthcheck
+
+	if settings.HTTP != nil {
+		url, err := d.substituteTemplate(settings.HTTP.URL)
+		if err != nil {
+			return nil, fmt.Errorf("could not populate healthcheck url %s; %w", settings.HTTP.URL, err)
+		}
+
+		check := *settings.HTTP
+		check.URL = url
+		checks = append(checks, healthcheck{
+			Name:  "http " + url,
+			Check: func(timeout time.Duration) error { return checkHTTP(client.DialTimeout, check, timeout) },
+		})
+	}
+
+	if settings.TCP != "" {
+		address, err := d.substituteTemplate(settings.TCP)
+		if err != nil {
+			return nil, fmt.Errorf("could not populate healthcheck address %s; %w", settings.TCP, err)
+		}
+
+		checks = append(checks, healthcheck{
+			Name:  "tcp " + address,
+			Check: func(timeout time.Duration) error { return checkTCP(client.DialTimeout, address, timeout) },
+		})
+	}
+
+	if settings.Command != "" {
+		command, err := d.substituteTemplate(settings.Command)
+		if err != nil {
+			return nil, fmt.Errorf("could not populate healthcheck command %s; %w", settings.Command, err)
+		}
+
+		checks = append(checks, healthcheck{
+			Name:  "command " + command,
+			Check: func(timeout time.Duration) error { return checkCommand(client, command, timeout) },
+		})
+	}
+
+	return checks, nil
+}
+
+// runHealthchecks attempts every configured check until all of them pass or the retries run out
+func (d *Deploy) runHealthchecks(client *sshutil.Client) error {
+	if !d.Healthcheck.Enabled() {
+		return nil
+	}
+
+	checks, err := d.healthchecks(client)
+	if err != nil {
+		return err
+	}
+
+	retries, interval, timeout := healthcheckSettings(d.Healthcheck)
+
+	fmt.Fprintln(client.Stdout, "running healthchecks")
+	return retry(retries, interval, client.Stdout, func() error {
+		for _, check := range checks {
+			err := check.Check(timeout)
+			if err != nil {
+				return fmt.Errorf("%s: %w", check.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// planHealthchecks prints the checks that would be run after the deploy commands
+func (d *Deploy) planHealthchecks() error {
+	if !d.Healthcheck.Enabled() {
+		return nil
+	}
+
+	checks, err := d.healthchecks(nil)
+	if err != nil {
+		return err
+	}
+
+	retries, interval, timeout := healthcheckSettings(d.Healthcheck)
+	for _, check := range checks {
+		utils.PrintPlan("healthcheck "+d.Host, fmt.Sprintf("%s (%d retries every %s, %s timeout)",
+			check.Name, retries, interval, timeout))
+	}
+
+	return nil
+}
+
+// healthcheckSettings returns the retries, interval and timeout to use, filling in defaults
+func healthcheckSettings(settings config.Healthcheck) (int, time.Duration, time.Duration) {
+	retries, interval, timeout := settings.Retries, settings.Interval, settings.Timeout
+	if retries <= 0 {
+		retries = defaultHealthcheckRetries
+	}
+	if interval <= 0 {
+		interval = defaultHealthcheckInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultHealthcheckTimeout
+	}
+
+	return retries, interval, timeout
+}
+
+// retry calls attempt until it succeeds, retrying up to the number of times given
+func retry(retries int, interval time.Duration, log io.Writer, attempt func() error) error {
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		if i >= retries {
+			return fmt.Errorf("still failing after %d attempts: %w", i+1, err)
+		}
+
+		fmt.Fprintf(log, "healthcheck failed, retrying in %s: %v\n", interval, err)
+		time.Sleep(interval)
+	}
+}
+
+// checkHTTP makes a GET request over connections opened with dial and checks the response
+// status and body
+func checkHTTP(dial dialFunc, check config.HTTPCheck, timeout time.Duration) error {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dial(network, address, timeout)
+		},
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	client := http.Client{Transport: transport, Timeout: timeout}
+	response, err := client.Get(check.URL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	status := check.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if response.StatusCode != status {
+		return fmt.Errorf("expected status %d; got %d", status, response.StatusCode)
+	}
+
+	if check.Body == "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %w", err)
+	}
+
+	if !strings.Contains(string(body), check.Body) {
+		return fmt.Errorf("response body does not contain %q", check.Body)
+	}
+
+	return nil
+}
+
+// checkTCP opens and closes a connection to the address given using dial
+func checkTCP(dial dialFunc, address string, timeout time.Duration) error {
+	conn, err := dial("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// checkCommand runs a command on the host and checks it exits 0
+func checkCommand(client *sshutil.Client, command string, timeout time.Duration) error {
+	_, err := client.OutputTimeout(command, timeout)
+	return err
+}
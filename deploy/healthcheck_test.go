@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/clintjedwards/toolkit/config"
+)
+
+func TestCheckHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "status: ok")
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		check   config.HTTPCheck
+		healthy bool
+	}{
+		"default status":  {check: config.HTTPCheck{URL: server.URL}, healthy: true},
+		"matching body":   {check: config.HTTPCheck{URL: server.URL, Body: "ok"}, healthy: true},
+		"wrong status":    {check: config.HTTPCheck{URL: server.URL, Status: http.StatusNoContent}},
+		"missing body":    {check: config.HTTPCheck{URL: server.URL, Body: "degraded"}},
+		"unreachable url": {check: config.HTTPCheck{URL: "http://127.0.0.1:1"}},
+	}
+
+	for name, test := range tests {
+		err := checkHTTP(net.DialTimeout, test.check, time.Second)
+		if test.healthy && err != nil {
+			t.Errorf("%s: expected check to pass; got %v", name, err)
+		}
+		if !test.healthy && err == nil {
+			t.Errorf("%s: expected check to fail", name)
+		}
+	}
+}
+
+func TestCheckTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	address := listener.Addr().String()
+
+	err = checkTCP(net.DialTimeout, address, time.Second)
+	if err != nil {
+		t.Errorf("expected check to pass; got %v", err)
+	}
+
+	listener.Close()
+	err = checkTCP(net.DialTimeout, address, time.Second)
+	if err == nil {
+		t.Errorf("expected check to fail once the listener is closed")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	attempts := 0
+	err := retry(2, time.Millisecond, ioutil.Discard, func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("not ready")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected success on the last retry; got %v", err)
+	}
+
+	attempts = 0
+	err = retry(2, time.Millisecond, ioutil.Discard, func() error {
+		attempts++
+		return fmt.Errorf("not ready")
+	})
+	if err == nil || attempts != 3 {
+		t.Errorf("expected failure after 3 attempts; got %d attempts and error %v", attempts, err)
+	}
+}
@@ -0,0 +1,34 @@
+package deploy
+
+import (
+	"fmt"
+
+	"github.com/clintjedwards/toolkit/sshutil"
+)
+
+// forVersion returns a copy of the deploy that targets a different version already present on the host
+func (d *Deploy) forVersion(version string) *Deploy {
+	previous := *d
+	previous.Version = version
+	previous.UploadFilePath = fmt.Sprintf("/tmp/%s_%s", d.Name, version)
+	return &previous
+}
+
+// rollback re-runs the deploy commands with the previous version's binary, which is still on the
+// host from when that version was deployed
+func (d *Deploy) rollback(client *sshutil.Client, previousVersion string) error {
+	previous := d.forVersion(previousVersion)
+
+	_, err := client.Output(fmt.Sprintf("test -f %s", previous.UploadFilePath))
+	if err != nil {
+		return fmt.Errorf("binary for version %s is no longer at %s:%s", previousVersion, d.Host, previous.UploadFilePath)
+	}
+
+	commandList, err := previous.commandList()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(client.Stdout, "rolling back to version %s\n", previousVersion)
+	return client.RunCommands(commandList)
+}
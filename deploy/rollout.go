@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"sync"
+)
+
+// Strategy controls how a deploy is spread across multiple hosts
+type Strategy struct {
+	Parallel    int  // number of hosts deployed to at the same time; values below 1 are treated as 1
+	Canary      bool // deploy to the first host on its own and halt if it fails
+	MaxFailures int  // number of failed hosts tolerated before the rest of the rollout is halted
+}
+
+// HostResult is the outcome of deploying to a single host
+type HostResult struct {
+	Host    string
+	Err     error
+	Skipped bool // true if the rollout was halted before this host was attempted
+}
+
+// rollout runs deployHost for every host according to the strategy given and returns
+// a result for every host in the original order
+func rollout(hosts []string, strategy Strategy, deployHost func(host string) error) []HostResult {
+	results := make([]HostResult, len(hosts))
+	for i, host := range hosts {
+		results[i] = HostResult{Host: host, Skipped: true}
+	}
+
+	remaining := hosts
+	offset := 0
+
+	if strategy.Canary && len(hosts) > 1 {
+		err := deployHost(hosts[0])
+		results[0] = HostResult{Host: hosts[0], Err: err}
+		if err != nil {
+			return results
+		}
+
+		remaining = hosts[1:]
+		offset = 1
+	}
+
+	parallel := strategy.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var mutex sync.Mutex
+	failures := 0
+	halted := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return failures > strategy.MaxFailures
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+
+	for i, host := range remaining {
+		slots <- struct{}{}
+
+		// hosts already in flight are allowed to finish once the rollout is halted
+		if halted() {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, host string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			err := deployHost(host)
+
+			mutex.Lock()
+			results[index] = HostResult{Host: host, Err: err}
+			if err != nil {
+				failures++
+			}
+			mutex.Unlock()
+		}(offset+i, host)
+	}
+
+	wg.Wait()
+
+	return results
+}
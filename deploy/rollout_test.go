@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRollout(t *testing.T) {
+	hosts := []string{"canary", "web-1", "web-2", "web-3"}
+
+	tests := map[string]struct {
+		strategy Strategy
+		failing  map[string]bool
+		skipped  []string
+	}{
+		"all succeed": {
+			strategy: Strategy{Parallel: 2},
+		},
+		"canary failure halts rollout": {
+			strategy: Strategy{Parallel: 2, Canary: true},
+			failing:  map[string]bool{"canary": true},
+			skipped:  []string{"web-1", "web-2", "web-3"},
+		},
+		"max failures halts rollout": {
+			strategy: Strategy{Parallel: 1, MaxFailures: 1},
+			failing:  map[string]bool{"canary": true, "web-1": true},
+			skipped:  []string{"web-2", "web-3"},
+		},
+	}
+
+	for name, test := range tests {
+		results := rollout(hosts, test.strategy, func(host string) error {
+			if test.failing[host] {
+				return fmt.Errorf("failed")
+			}
+			return nil
+		})
+
+		skipped := map[string]bool{}
+		for _, host := range test.skipped {
+			skipped[host] = true
+		}
+
+		for _, result := range results {
+			if result.Skipped != skipped[result.Host] {
+				t.Errorf("%s: host %s skipped=%t; expected %t", name, result.Host, result.Skipped, skipped[result.Host])
+			}
+			if !result.Skipped && (result.Err != nil) != test.failing[result.Host] {
+				t.Errorf("%s: host %s has unexpected result: %v", name, result.Host, result.Err)
+			}
+		}
+	}
+}
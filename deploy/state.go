@@ -0,0 +1,133 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/user"
+	"path"
+	"time"
+
+	"github.com/clintjedwards/toolkit/sshutil"
+)
+
+// stateDir is where deploy state is kept on each host, relative to the ssh user's home directory
+const stateDir = ".toolkit"
+
+// maxHistory is the number of deployments kept in a host's state file
+const maxHistory = 50
+
+// Deployment records a single successful deploy to a host
+type Deployment struct {
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`             // local user that ran the deploy
+	Commit  string    `json:"commit,omitempty"` // commit the binary was built from if known
+}
+
+// State is the record of deploys kept on each host
+type State struct {
+	Deployments []Deployment `json:"deployments"` // oldest first
+}
+
+// Current returns the deployment running on the host or nil if there has not been one
+func (s *State) Current() *Deployment {
+	if len(s.Deployments) == 0 {
+		return nil
+	}
+
+	return &s.Deployments[len(s.Deployments)-1]
+}
+
+// add appends a deployment, dropping the oldest ones past maxHistory
+func (s *State) add(d Deployment) {
+	s.Deployments = append(s.Deployments, d)
+	if len(s.Deployments) > maxHistory {
+		s.Deployments = s.Deployments[len(s.Deployments)-maxHistory:]
+	}
+}
+
+// stateFile returns the path of the state file for a project on a host
+func stateFile(projectName string) string {
+	return path.Join(stateDir, projectName, "state.json")
+}
+
+// ReadState returns the deploy state for a project on the host connected to. The state is
+// empty if the project has never been deployed there
+func ReadState(client *sshutil.Client, projectName string) (*State, error) {
+	output, err := client.Output(fmt.Sprintf("cat %s 2>/dev/null || true", stateFile(projectName)))
+	if err != nil {
+		return nil, fmt.Errorf("could not read deploy state: %w", err)
+	}
+
+	state := &State{}
+	if len(bytes.TrimSpace(output)) == 0 {
+		return state, nil
+	}
+
+	err = json.Unmarshal(output, state)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse deploy state in %s: %w", stateFile(projectName), err)
+	}
+
+	return state, nil
+}
+
+// writeState replaces the deploy state for a project on the host connected to
+func writeState(client *sshutil.Client, projectName string, state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Output("mkdir -p " + path.Dir(stateFile(projectName)))
+	if err != nil {
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	err = client.Upload(bytes.NewReader(data), int64(len(data)), 0644, stateFile(projectName), nil)
+	if err != nil {
+		return fmt.Errorf("could not write deploy state: %w", err)
+	}
+
+	return nil
+}
+
+// previousVersion returns the version last deployed successfully to the host or an empty string
+// if the project has never been deployed there
+func (d *Deploy) previousVersion(client *sshutil.Client) (string, error) {
+	state, err := ReadState(client, d.Name)
+	if err != nil {
+		return "", err
+	}
+
+	current := state.Current()
+	if current == nil {
+		return "", nil
+	}
+
+	return current.Version, nil
+}
+
+// recordDeployment adds the deploy to the host's history, making it the known-good version
+func (d *Deploy) recordDeployment(client *sshutil.Client) error {
+	state, err := ReadState(client, d.Name)
+	if err != nil {
+		return err
+	}
+
+	username := ""
+	currentUser, err := user.Current()
+	if err == nil {
+		username = currentUser.Username
+	}
+
+	state.add(Deployment{
+		Version: d.Version,
+		Time:    time.Now().UTC(),
+		User:    username,
+		Commit:  d.Commit,
+	})
+
+	return writeState(client, d.Name, state)
+}
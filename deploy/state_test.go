@@ -0,0 +1,29 @@
+package deploy
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeployStateAdd(t *testing.T) {
+	state := &State{}
+	if state.Current() != nil {
+		t.Errorf("expected no current deployment for empty state")
+	}
+
+	for i := 0; i < maxHistory+5; i++ {
+		state.add(Deployment{Version: fmt.Sprintf("1.0.%d", i)})
+	}
+
+	if len(state.Deployments) != maxHistory {
+		t.Errorf("expected history to be capped at %d; got %d", maxHistory, len(state.Deployments))
+	}
+
+	if state.Deployments[0].Version != "1.0.5" {
+		t.Errorf("expected oldest deployments to be dropped; oldest is %s", state.Deployments[0].Version)
+	}
+
+	if state.Current().Version != fmt.Sprintf("1.0.%d", maxHistory+4) {
+		t.Errorf("expected latest deployment to be current; got %s", state.Current().Version)
+	}
+}
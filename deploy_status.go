@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/clintjedwards/toolkit/deploy"
+	"github.com/clintjedwards/toolkit/github"
+	"github.com/clintjedwards/toolkit/sshutil"
+	"github.com/spf13/cobra"
+)
+
+var cmdDeployStatus = &cobra.Command{
+	Use:   "status <[user@]host[:port]>",
+	Short: "Shows the version currently deployed to a host",
+	Long: `Reads the deploy state recorded on the host by previous deploys and prints the
+version currently deployed along with when, by whom and from which commit.`,
+	Args:          cobra.ExactArgs(1),
+	RunE:          runDeployStatusCmd,
+	SilenceUsage:  true,
+	SilenceErrors: true, // printed by main
+}
+
+var cmdDeployHistory = &cobra.Command{
+	Use:   "history <[user@]host[:port]>",
+	Short: "Lists the versions previously deployed to a host",
+	Long: `Reads the deploy state recorded on the host by previous deploys and prints every
+deployment kept, newest first.`,
+	Args:          cobra.ExactArgs(1),
+	RunE:          runDeployHistoryCmd,
+	SilenceUsage:  true,
+	SilenceErrors: true, // printed by main
+}
+
+func runDeployStatusCmd(cmd *cobra.Command, args []string) error {
+	state, err := hostState(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	current := state.Current()
+	if current == nil {
+		fmt.Printf("%s: nothing deployed\n", args[0])
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "host:\t%s\n", args[0])
+	fmt.Fprintf(w, "version:\t%s\n", current.Version)
+	fmt.Fprintf(w, "deployed:\t%s\n", current.Time.Local().Format(time.RFC1123))
+	fmt.Fprintf(w, "by:\t%s\n", current.User)
+	if current.Commit != "" {
+		fmt.Fprintf(w, "commit:\t%s\n", current.Commit)
+	}
+
+	return w.Flush()
+}
+
+func runDeployHistoryCmd(cmd *cobra.Command, args []string) error {
+	state, err := hostState(cmd, args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(state.Deployments) == 0 {
+		fmt.Printf("%s: nothing deployed\n", args[0])
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDEPLOYED\tBY\tCOMMIT")
+	for i := len(state.Deployments) - 1; i >= 0; i-- {
+		deployment := state.Deployments[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", deployment.Version,
+			deployment.Time.Local().Format(time.RFC1123), deployment.User, deployment.Commit)
+	}
+
+	return w.Flush()
+}
+
+// hostState connects to a host and reads the deploy state of the project in the config file
+func hostState(cmd *cobra.Command, host string) (*deploy.State, error) {
+	config, projectRoot, err := loadDeployConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	_, projectName, err := github.ParseGithubURL(config.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse github URL: %w", err)
+	}
+
+	sshOptions, err := deploy.SSHOptions(config.SSH, projectRoot, host)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sshutil.Dial(host, sshOptions)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to server: %w", err)
+	}
+	defer client.Close()
+
+	return deploy.ReadState(client, projectName)
+}
+
+func init() {
+	cmdDeploy.AddCommand(cmdDeployStatus)
+	cmdDeploy.AddCommand(cmdDeployHistory)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDevVersion(t *testing.T) {
+	tests := map[string]struct {
+		version  string
+		commit   string
+		expected string
+	}{
+		"release":            {version: "1.2.0", commit: "4f2a9c1d8e", expected: "1.2.0-dev+4f2a9c1"},
+		"prerelease":         {version: "1.2.0-rc.1", commit: "4f2a9c1d8e", expected: "1.2.0-rc.1.dev+4f2a9c1"},
+		"existing metadata":  {version: "1.2.0+build.5", commit: "4f2a9c1d8e", expected: "1.2.0-dev+4f2a9c1"},
+		"not a git checkout": {version: "1.2.0", expected: "1.2.0-dev"},
+	}
+
+	for name, test := range tests {
+		version, err := devVersion(test.version, test.commit)
+		if err != nil {
+			t.Errorf("%s: could not create dev version: %v", name, err)
+			continue
+		}
+		if version != test.expected {
+			t.Errorf("%s: expected version %s; got %s", name, test.expected, version)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package github
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/utils"
+)
+
+// ChecksumsFileName is the name of the release asset containing checksums for all other assets
+const ChecksumsFileName string = "SHA256SUMS"
+
+const (
+	archiveTarGz string = "tar.gz"
+	archiveZip   string = "zip"
+)
+
+// AssetName returns the release asset name for a project and target; the target may be empty
+func AssetName(projectName, target string) string {
+	if target == "" {
+		return projectName
+	}
+
+	return fmt.Sprintf("%s_%s", projectName, target)
+}
+
+// ArchiveName returns the name of an asset once it has been packaged in the archive format given
+// returns the name unchanged if format is empty
+func ArchiveName(name, format string) string {
+	if format == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s.%s", name, format)
+}
+
+// PackageAssets archives each asset according to the release settings and, if enabled,
+// adds a checksums file covering every asset. Returns the assets that should be uploaded
+func (r *Release) PackageAssets(assets []Asset, settings config.Release) ([]Asset, error) {
+	var packaged []Asset
+
+	for _, asset := range assets {
+		if settings.Archive == "" {
+			packaged = append(packaged, asset)
+			continue
+		}
+
+		archive, err := archiveAsset(asset, settings.Archive, r.stagingPath(ArchiveName(asset.Name, settings.Archive)))
+		if err != nil {
+			return nil, fmt.Errorf("could not archive asset %s: %w", asset.Name, err)
+		}
+
+		packaged = append(packaged, archive)
+	}
+
+	if !settings.Checksums {
+		return packaged, nil
+	}
+
+	checksums, err := writeChecksums(packaged, r.stagingPath(ChecksumsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("could not create checksums file: %w", err)
+	}
+
+	return append(packaged, checksums), nil
+}
+
+// PlanPackageAssets prints the steps PackageAssets would take and returns the assets it would produce
+// without reading or writing any files
+func (r *Release) PlanPackageAssets(assets []Asset, settings config.Release) []Asset {
+	var packaged []Asset
+
+	for _, asset := range assets {
+		if settings.Archive == "" {
+			packaged = append(packaged, asset)
+			continue
+		}
+
+		archive := Asset{
+			Name: ArchiveName(asset.Name, settings.Archive),
+			Path: r.stagingPath(ArchiveName(asset.Name, settings.Archive)),
+		}
+		utils.PrintPlan("package", fmt.Sprintf("archive %s into %s", asset.Path, archive.Path))
+		packaged = append(packaged, archive)
+	}
+
+	if !settings.Checksums {
+		return packaged
+	}
+
+	var names []string
+	for _, asset := range packaged {
+		names = append(names, asset.Name)
+	}
+
+	checksums := Asset{Name: ChecksumsFileName, Path: r.stagingPath(ChecksumsFileName)}
+	utils.PrintPlan("package", fmt.Sprintf("write checksums for %s into %s", strings.Join(names, ","), checksums.Path))
+
+	return append(packaged, checksums)
+}
+
+// stagingPath returns the local path a generated release file with the name given is written to
+// ex. /tmp/<project>_<version>_<name>
+func (r *Release) stagingPath(name string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s_%s_%s", r.ProjectName, r.Version, name))
+}
+
+// archiveAsset packages an asset into an archive at path
+// the file inside the archive is named after the asset and keeps its file mode
+func archiveAsset(asset Asset, format, path string) (Asset, error) {
+	archive := Asset{
+		Name: ArchiveName(asset.Name, format),
+		Path: path,
+	}
+
+	src, err := os.Open(asset.Path)
+	if err != nil {
+		return Asset{}, err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return Asset{}, err
+	}
+
+	dst, err := os.Create(archive.Path)
+	if err != nil {
+		return Asset{}, err
+	}
+	defer dst.Close()
+
+	switch format {
+	case archiveTarGz:
+		err = writeTarGz(dst, src, info, asset.Name)
+	case archiveZip:
+		err = writeZip(dst, src, info, asset.Name)
+	default:
+		err = fmt.Errorf("unknown archive format %q; must be one of: %s, %s", format, archiveTarGz, archiveZip)
+	}
+	if err != nil {
+		return Asset{}, err
+	}
+
+	return archive, dst.Close()
+}
+
+func writeTarGz(dst io.Writer, src io.Reader, info os.FileInfo, name string) error {
+	gzipWriter := gzip.NewWriter(dst)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, src)
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return gzipWriter.Close()
+}
+
+func writeZip(dst io.Writer, src io.Reader, info os.FileInfo, name string) error {
+	zipWriter := zip.NewWriter(dst)
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	fileWriter, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fileWriter, src)
+	if err != nil {
+		return err
+	}
+
+	return zipWriter.Close()
+}
+
+// writeChecksums writes a sha256sum compatible checksums file for the assets given to path
+func writeChecksums(assets []Asset, path string) (Asset, error) {
+	var b strings.Builder
+
+	for _, asset := range assets {
+		sum, err := FileChecksum(asset.Path)
+		if err != nil {
+			return Asset{}, err
+		}
+
+		fmt.Fprintf(&b, "%s  %s\n", sum, asset.Name)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return Asset{}, err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(b.String())
+	if err != nil {
+		return Asset{}, err
+	}
+
+	return Asset{Name: ChecksumsFileName, Path: path}, file.Close()
+}
+
+// FileChecksum returns the hex encoded sha256 sum of the file at path
+func FileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ExtractArchive writes the first regular file found in an archive created by PackageAssets to dst
+func ExtractArchive(path, format string, dst io.Writer) error {
+	switch format {
+	case archiveTarGz:
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+
+		tarReader := tar.NewReader(gzipReader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				return fmt.Errorf("archive %s does not contain any files", path)
+			}
+			if err != nil {
+				return err
+			}
+
+			if header.Typeflag == tar.TypeReg {
+				_, err = io.Copy(dst, tarReader)
+				return err
+			}
+		}
+	case archiveZip:
+		zipReader, err := zip.OpenReader(path)
+		if err != nil {
+			return err
+		}
+		defer zipReader.Close()
+
+		for _, file := range zipReader.File {
+			if !file.Mode().IsRegular() {
+				continue
+			}
+
+			contents, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer contents.Close()
+
+			_, err = io.Copy(dst, contents)
+			return err
+		}
+
+		return fmt.Errorf("archive %s does not contain any files", path)
+	default:
+		return fmt.Errorf("unknown archive format %q; must be one of: %s, %s", format, archiveTarGz, archiveZip)
+	}
+}
+
+// ParseChecksums parses a sha256sum formatted checksums file into a map of file name to checksum
+func ParseChecksums(data []byte) map[string]string {
+	checksums := map[string]string{}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		// sha256sum marks files hashed in binary mode with a leading '*'
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+
+	return checksums
+}
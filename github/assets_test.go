@@ -0,0 +1,66 @@
+package github
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clintjedwards/toolkit/config"
+)
+
+func TestPackageAssets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	binaryPath := filepath.Join(dir, "toolkit_1.0.0")
+	err = ioutil.WriteFile(binaryPath, []byte("binary contents"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := &Release{ProjectName: "toolkit", Version: "1.0.0"}
+	defer os.Remove(release.stagingPath(ChecksumsFileName))
+	defer os.Remove(release.stagingPath("toolkit.tar.gz"))
+	defer os.Remove(release.stagingPath("toolkit.zip"))
+
+	for _, format := range []string{archiveTarGz, archiveZip} {
+		assets, err := release.PackageAssets([]Asset{{Name: "toolkit", Path: binaryPath}},
+			config.Release{Archive: format, Checksums: true})
+		if err != nil {
+			t.Fatalf("could not package assets: %v", err)
+		}
+
+		if len(assets) != 2 || assets[0].Name != "toolkit."+format || assets[1].Name != ChecksumsFileName {
+			t.Fatalf("unexpected assets for format %s: %+v", format, assets)
+		}
+
+		var extracted bytes.Buffer
+		err = ExtractArchive(assets[0].Path, format, &extracted)
+		if err != nil {
+			t.Fatalf("could not extract %s archive: %v", format, err)
+		}
+
+		if extracted.String() != "binary contents" {
+			t.Errorf("extracted %s archive contents incorrect; got %q", format, extracted.String())
+		}
+
+		checksums, err := ioutil.ReadFile(assets[1].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum, err := FileChecksum(assets[0].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ParseChecksums(checksums)["toolkit."+format] != sum {
+			t.Errorf("checksums file does not contain archive checksum; got %q", string(checksums))
+		}
+	}
+}
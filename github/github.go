@@ -34,6 +34,12 @@ type Release struct {
 	Commands    map[string][]string
 }
 
+// Asset is a file to be uploaded to a release
+type Asset struct {
+	Name string // name the file is uploaded under
+	Path string // local path of the file
+}
+
 // NewRelease creates a prepopulated release struct using the config file and other sources
 func NewRelease(config *config.Config, args []string, spinner *yacspin.Spinner) (*Release, error) {
 	// insert version into build struct
@@ -69,8 +75,9 @@ func NewRelease(config *config.Config, args []string, spinner *yacspin.Spinner)
 	}, nil
 }
 
-// CreateGithubRelease cuts a new release, tags the current commit with semver, and uploads the changelog as a description
-func (r *Release) CreateGithubRelease(tokenFile, binaryPath string, spinner *yacspin.Spinner) error {
+// CreateGithubRelease cuts a new release, tags the current commit with semver, uploads the changelog as a description
+// and uploads any assets given
+func (r *Release) CreateGithubRelease(tokenFile string, assets []Asset, spinner *yacspin.Spinner) error {
 	ctx := context.Background()
 
 	spinner.Message("Getting Github token")
@@ -98,31 +105,52 @@ func (r *Release) CreateGithubRelease(tokenFile, binaryPath string, spinner *yac
 		return err
 	}
 
-	if binaryPath == "" {
-		return nil
+	for _, asset := range assets {
+		spinner.Message(fmt.Sprintf("Uploading %s", asset.Name))
+		err := r.uploadAsset(ctx, client, createdRelease.GetID(), asset)
+		if err != nil {
+			return err
+		}
 	}
 
-	spinner.Message("Uploading binary")
-	_, err = os.Stat(binaryPath)
+	return nil
+}
+
+// uploadAsset uploads a single file to the release with the id given
+func (r *Release) uploadAsset(ctx context.Context, client *github.Client, releaseID int64, asset Asset) error {
+	_, err := os.Stat(asset.Path)
 	if os.IsNotExist(err) {
-		return fmt.Errorf("could not find binary file: %s; %w", binaryPath, err)
+		return fmt.Errorf("could not find asset file: %s; %w", asset.Path, err)
 	}
 
-	f, err := os.Open(binaryPath)
+	f, err := os.Open(asset.Path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	_, _, err = client.Repositories.UploadReleaseAsset(ctx, r.User, r.ProjectName, createdRelease.GetID(),
-		&github.UploadOptions{Name: r.ProjectName}, f)
+	_, _, err = client.Repositories.UploadReleaseAsset(ctx, r.User, r.ProjectName, releaseID,
+		&github.UploadOptions{Name: asset.Name}, f)
 	if err != nil {
-		return fmt.Errorf("could not upload binary file: %s; %w", binaryPath, err)
+		return fmt.Errorf("could not upload asset file: %s; %w", asset.Path, err)
 	}
 
 	return nil
 }
 
+// PlanGithubRelease prints the Github API calls that CreateGithubRelease would make without
+// contacting Github or requiring a token
+func (r *Release) PlanGithubRelease(assets []Asset) {
+	utils.PrintPlan("github", fmt.Sprintf("POST /repos/%s/%s/releases tag_name=v%s name=v%s",
+		r.User, r.ProjectName, r.Version, r.Version))
+	utils.PrintPlan("github", fmt.Sprintf("release body %q", string(r.Changelog)))
+
+	for _, asset := range assets {
+		utils.PrintPlan("github", fmt.Sprintf("POST /repos/%s/%s/releases/<id>/assets name=%s file=%s",
+			r.User, r.ProjectName, asset.Name, asset.Path))
+	}
+}
+
 // getGithubToken attempts to load a github token and returns an error if none exists
 func getGithubToken(tokenFile string) (token string, err error) {
 
@@ -174,6 +202,75 @@ func ParseGithubURL(githubURL string) (username, projectName string, err error)
 	return splitURL[0], splitURL[1], nil
 }
 
+// RepositoryFromRemote converts a github git remote url into the username/repo form used in
+// the config file. Both ssh (git@github.com:user/repo.git) and https remotes are understood
+func RepositoryFromRemote(remoteURL string) (string, error) {
+	repository := strings.TrimSpace(remoteURL)
+	repository = strings.TrimSuffix(repository, "/")
+	repository = strings.TrimSuffix(repository, ".git")
+
+	found := false
+	for _, prefix := range []string{"git@github.com:", "ssh://git@github.com/", "https://github.com/", "http://github.com/"} {
+		if strings.HasPrefix(repository, prefix) {
+			repository = strings.TrimPrefix(repository, prefix)
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return "", fmt.Errorf("remote %s is not a github repository", remoteURL)
+	}
+
+	_, _, err := ParseGithubURL(repository)
+	if err != nil {
+		return "", err
+	}
+
+	return repository, nil
+}
+
+// LatestVersion returns the highest semver tag in the local git repository
+// returns nil if the repository has no semver tags
+func LatestVersion() (*semver.Version, error) {
+	tags, err := utils.ExecuteBashCmd("git tag --list", os.Environ(), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not list git tags: %w; %s", err, tags)
+	}
+
+	return latestVersion(strings.Fields(string(tags))), nil
+}
+
+// latestVersion returns the highest version out of a list of tags; tags that are not
+// valid semver strings are ignored
+func latestVersion(tags []string) *semver.Version {
+	var latest *semver.Version
+
+	for _, tag := range tags {
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+		}
+	}
+
+	return latest
+}
+
+// GitCommit returns the commit a revision such as a tag points at or an empty string
+// if it is not in the local repository
+func GitCommit(revision string) string {
+	commit, err := utils.ExecuteBashCmd(fmt.Sprintf("git rev-list -n 1 %s", revision), os.Environ(), "")
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(commit))
+}
+
 // getVersionFull generates a long version string in format <semver>_<epoch>_<githash>
 func getVersionFull(semver string) (string, error) {
 	versionFmt := "%s_%s_%s"
@@ -0,0 +1,50 @@
+package github
+
+import (
+	"testing"
+)
+
+func TestLatestVersion(t *testing.T) {
+	tags := []string{"v0.1.0", "v1.2.0", "not-a-version", "v1.10.0", "v1.9.3", "v2.0.0-beta.1", "v1.10.0-rc.1"}
+
+	latest := latestVersion(tags)
+	if latest == nil {
+		t.Fatalf("expected a version to be found; got nil")
+	}
+
+	if latest.String() != "2.0.0-beta.1" {
+		t.Errorf("incorrect latest version; expected 2.0.0-beta.1; got %s", latest.String())
+	}
+
+	if latestVersion([]string{"release", "stable"}) != nil {
+		t.Errorf("expected no version to be found from tags without semver")
+	}
+}
+
+func TestRepositoryFromRemote(t *testing.T) {
+	remotes := map[string]string{
+		"git@github.com:clintjedwards/toolkit.git":       "clintjedwards/toolkit",
+		"https://github.com/clintjedwards/toolkit.git\n": "clintjedwards/toolkit",
+		"https://github.com/clintjedwards/toolkit":       "clintjedwards/toolkit",
+		"ssh://git@github.com/clintjedwards/toolkit.git": "clintjedwards/toolkit",
+	}
+
+	for remote, expected := range remotes {
+		repository, err := RepositoryFromRemote(remote)
+		if err != nil {
+			t.Errorf("could not parse remote %q: %v", remote, err)
+			continue
+		}
+
+		if repository != expected {
+			t.Errorf("incorrect repository for remote %q; expected %s; got %s", remote, expected, repository)
+		}
+	}
+
+	for _, remote := range []string{"git@gitlab.com:clintjedwards/toolkit.git", "https://github.com/clintjedwards"} {
+		_, err := RepositoryFromRemote(remote)
+		if err == nil {
+			t.Errorf("expected error for remote %q", remote)
+		}
+	}
+}
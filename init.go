@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/clintjedwards/toolkit/github"
+	"github.com/clintjedwards/toolkit/utils"
+	"github.com/spf13/cobra"
+)
+
+var cmdInit = &cobra.Command{
+	Use:   "init",
+	Short: "Writes a starter config file for the project in the current directory",
+	Long: `Detects the github repository from the origin remote and the kind of project in the
+current directory (Go module, Makefile targets, Dockerfile) and writes a starter
+.toolkit.yml with build and deploy commands to suit. Review the file and run
+"toolkit config validate" after editing it.
+
+An existing config file is not overwritten unless --force is given. Since config files
+are also found in parent directories up to the git root, a config file in a parent
+directory stops a new one being written here unless --force is given too.`,
+	Args:          cobra.NoArgs,
+	RunE:          runInitCmd,
+	SilenceUsage:  true,
+	SilenceErrors: true, // printed by main
+}
+
+// project is what could be detected about the project a config file is being written for
+type project struct {
+	Repository  string // in form username/project_name; empty if there is no github origin remote
+	GoModule    bool
+	MakeTargets []string
+	Dockerfile  bool
+}
+
+// makeTargetPattern matches rule lines in a Makefile, capturing the targets
+var makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9_./-]+(?:\s+[A-Za-z0-9_./-]+)*)\s*::?(?:[^=]|$)`)
+
+func runInitCmd(cmd *cobra.Command, args []string) error {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(workingDir, defaultConfigPath)
+	force, _ := cmd.Flags().GetBool("force")
+
+	// other commands use the first config file found up to the git root, so a config file in a
+	// parent directory already covers this one
+	existing, err := config.Find(workingDir, defaultConfigPath)
+	if err == nil && !force {
+		if existing == path {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+		}
+
+		return fmt.Errorf("%s already exists and is used from this directory; use --force to write "+
+			"another config file here that takes its place", existing)
+	}
+
+	project, err := detectProject(workingDir)
+	if err != nil {
+		return err
+	}
+
+	contents := project.config()
+
+	dryRun, _ := cmd.Flags().GetBool("dryRun")
+	if dryRun {
+		utils.PrintPlan("write", path)
+		fmt.Print(contents)
+		return nil
+	}
+
+	err = ioutil.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write config file: %w", err)
+	}
+
+	fmt.Printf("wrote %s\n", path)
+	if project.Repository == "" {
+		fmt.Println("no github origin remote found; set repository in the config file")
+	}
+
+	return nil
+}
+
+// detectProject looks at the git remote and files in dir to work out what kind of project it is
+func detectProject(dir string) (project, error) {
+	p := project{}
+
+	remote, err := utils.ExecuteBashCmd("git remote get-url origin", os.Environ(), dir)
+	if err == nil {
+		repository, err := github.RepositoryFromRemote(string(remote))
+		if err == nil {
+			p.Repository = repository
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "go.mod"))
+	p.GoModule = err == nil
+
+	_, err = os.Stat(filepath.Join(dir, "Dockerfile"))
+	p.Dockerfile = err == nil
+
+	makefile, err := ioutil.ReadFile(filepath.Join(dir, "Makefile"))
+	if err != nil && !os.IsNotExist(err) {
+		return project{}, fmt.Errorf("could not read Makefile: %w", err)
+	}
+	p.MakeTargets = makeTargets(makefile)
+
+	return p, nil
+}
+
+// makeTargets returns the targets defined in a Makefile, leaving out special targets such as .PHONY
+// and pattern rules
+func makeTargets(makefile []byte) []string {
+	targets := []string{}
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(makefile))
+	for scanner.Scan() {
+		match := makeTargetPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		for _, target := range strings.Fields(match[1]) {
+			if strings.HasPrefix(target, ".") || strings.Contains(target, "%") || seen[target] {
+				continue
+			}
+
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+func (p project) hasMakeTarget(name string) bool {
+	for _, target := range p.MakeTargets {
+		if target == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// config returns the contents of a starter config file for the project
+func (p project) config() string {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	name := "<name>"
+	line(`# Generated by "toolkit init"; run "toolkit config validate" after editing`)
+	if p.Repository == "" {
+		line("repository: username/project_name # set to the github repository to release to")
+	} else {
+		line("repository: %s", p.Repository)
+		name = p.Repository[strings.Index(p.Repository, "/")+1:]
+	}
+
+	line("")
+	line("commands:")
+	line("  # run by build and release; the binary must be written to {{.Path}}")
+	line("  # variables: ProjectName, ProjectRoot, Path, Version, VersionFull, OS, Arch, Target")
+	if len(p.MakeTargets) != 0 {
+		line("  # Makefile targets found: %s", strings.Join(p.MakeTargets, ", "))
+	}
+	line("  build:")
+	switch {
+	case p.GoModule:
+		line("    - cd {{.ProjectRoot}} && go test ./...")
+		line(`    - cd {{.ProjectRoot}} && go build -ldflags '-X "main.version={{.VersionFull}}"' -o {{.Path}}`)
+	case p.hasMakeTarget("build"):
+		if p.hasMakeTarget("test") {
+			line("    - make -C {{.ProjectRoot}} test")
+		}
+		line("    - make -C {{.ProjectRoot}} build")
+		line("    # copy the binary make produces to {{.Path}}, for example:")
+		line("    # - cp {{.ProjectRoot}}/bin/%s {{.Path}}", name)
+	default:
+		line("    # add commands that build the project into {{.Path}}")
+	}
+	if p.Dockerfile {
+		line("    # a Dockerfile was found; to build an image alongside the binary:")
+		line("    # - docker build -t %s:{{.Version}} {{.ProjectRoot}}", name)
+	}
+
+	line("")
+	line("  # run on each host by deploy after the binary is uploaded to {{.UploadFilePath}}")
+	line("  deploy:")
+	line("    # - sudo install -m 0755 {{.UploadFilePath}} /usr/local/bin/%s", name)
+	line("    # - sudo systemctl restart %s", name)
+
+	return b.String()
+}
+
+func init() {
+	cmdInit.Flags().Bool("force", false, "overwrite an existing config file")
+
+	rootCmd.AddCommand(cmdInit)
+}
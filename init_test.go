@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clintjedwards/toolkit/config"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMakeTargets(t *testing.T) {
+	makefile := []byte(`VERSION := 1.0.0
+GOFLAGS ?= -mod=mod
+.PHONY: build test
+
+build: deps
+	go build -o bin/app
+test lint:
+	go test ./...
+%.o: %.c
+	cc -c $<
+build:: extra
+`)
+
+	expected := []string{"build", "test", "lint"}
+	targets := makeTargets(makefile)
+	if !cmp.Equal(expected, targets) {
+		t.Errorf("incorrect targets; Diff below: \n%v", cmp.Diff(expected, targets))
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolkit_init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	projects := map[string]project{
+		"go module": {Repository: "clintjedwards/toolkit", GoModule: true, Dockerfile: true},
+		"makefile":  {MakeTargets: []string{"build", "test"}},
+		"unknown":   {},
+	}
+
+	// every starter config must load without changes
+	for name, project := range projects {
+		path := filepath.Join(dir, name+".yml")
+		err := ioutil.WriteFile(path, []byte(project.config()), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = (&config.Config{}).Load(path)
+		if err != nil {
+			t.Errorf("%s: generated config is not valid: %v", name, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/clintjedwards/toolkit/config"
 	"github.com/spf13/cobra"
 )
 
@@ -13,12 +14,29 @@ var rootCmd = &cobra.Command{
 	Short: "Helper for simple releases",
 }
 
-const defaultConfigPath = "./.toolkit.yml"
+const defaultConfigPath = ".toolkit.yml"
+
+// getConfigPath returns the config file given with --config, or if it wasn't given, finds the
+// config file in the current directory or one of its parents up to the root of the git repository
+func getConfigPath(cmd *cobra.Command) (string, error) {
+	if cmd.Flags().Changed("config") {
+		return cmd.Flags().GetString("config")
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return config.Find(workingDir, defaultConfigPath)
+}
 
 func main() {
 	rootCmd.PersistentFlags().Bool("hideOutput", false, "Hide output from commands")
 	rootCmd.PersistentFlags().Bool("echoCommands", false, "Print commands before running")
-	rootCmd.PersistentFlags().StringP("config", "c", ".toolkit.yml", "Path of toolkit config file")
+	rootCmd.PersistentFlags().Bool("dryRun", false, "Print the commands and API calls that would be made without running them")
+	rootCmd.PersistentFlags().StringP("config", "c", defaultConfigPath,
+		"Path of toolkit config file; if not given it is searched for in the current directory and its parents up to the git root")
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -1,33 +1,51 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/clintjedwards/toolkit/changelog"
 	"github.com/clintjedwards/toolkit/config"
 	"github.com/clintjedwards/toolkit/github"
+	"github.com/clintjedwards/toolkit/utils"
 	"github.com/spf13/cobra"
 	"github.com/theckman/yacspin"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 const binaryPathFmt string = "/tmp/%s_%s"
 
 var cmdRelease = &cobra.Command{
-	Use:   "release <semver>",
+	Use:   "release [semver]",
 	Short: "Controls the release process for an application",
 	Long: `The release command uses semantic versioning to build a new version
 of the provided application and create a new github release.
 
+If no semver is given the latest version tag is looked up and the user is
+prompted to pick the next patch, minor or major version.
+
+Build artifacts and any files matching the release assets globs in the config
+file are uploaded to the release, optionally packaged as tar.gz or zip archives
+along with a SHA256SUMS file.
+
 tokenFile should contain nothing but the github token with access to repo
 `,
-	Args: cobra.MinimumNArgs(1),
+	Args: cobra.MaximumNArgs(1),
 	Run:  runReleaseCmd,
 }
 
-func initSpinner(suffix string) (*yacspin.Spinner, error) {
+// initSpinner creates a new spinner that writes to the writer given
+func initSpinner(suffix string, writer io.Writer) (*yacspin.Spinner, error) {
 	cfg := yacspin.Config{
+		Writer:            writer,
 		Frequency:         100 * time.Millisecond,
 		CharSet:           yacspin.CharSets[14],
 		Suffix:            " " + suffix,
@@ -49,16 +67,59 @@ func initSpinner(suffix string) (*yacspin.Spinner, error) {
 // First we need to open a file where user can set the semver, changelog contents,
 // then we can insert that changelog contents into the source files before we call make to build
 func runReleaseCmd(cmd *cobra.Command, args []string) {
-	configFile, _ := cmd.Flags().GetString("config")
+	configFile, err := getConfigPath(cmd)
+	if err != nil {
+		fmt.Printf("could not find config file: %v\n", err)
+		os.Exit(1)
+		return
+	}
+
 	config := &config.Config{}
-	err := config.Load(configFile)
+	err = config.Load(configFile)
 	if err != nil {
 		fmt.Printf("could not load config file: %v\n", err)
 		os.Exit(1)
 		return
 	}
 
-	spinner, err := initSpinner(fmt.Sprintf("Releasing v%s of %s", args[0], config.Repository))
+	// paths in the config file are relative to it rather than to the working directory
+	projectRoot, err := filepath.Abs(filepath.Dir(configFile))
+	if err != nil {
+		fmt.Printf("could not determine project root: %v\n", err)
+		os.Exit(1)
+		return
+	}
+
+	changelogFile := ""
+	if config.Changelog != "" {
+		changelogFile = projectPath(projectRoot, config.Changelog)
+	}
+
+	if len(args) == 0 {
+		// without a terminal there is nobody to answer the prompt, so a version must be given
+		if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("could not determine version: no version given and stdin is not a terminal to prompt on")
+			os.Exit(1)
+			return
+		}
+
+		version, err := promptVersion(os.Stdin, os.Stderr)
+		if err != nil {
+			fmt.Printf("could not determine version: %v\n", err)
+			os.Exit(1)
+			return
+		}
+		args = []string{version}
+	}
+
+	// during a dry run the plan is printed to stdout so keep the spinner out of the way
+	dryRun, _ := cmd.Flags().GetBool("dryRun")
+	spinnerWriter := io.Writer(os.Stdout)
+	if dryRun {
+		spinnerWriter = os.Stderr
+	}
+
+	spinner, err := initSpinner(fmt.Sprintf("Releasing v%s of %s", args[0], config.Repository), spinnerWriter)
 	if err != nil {
 		fmt.Println("could not init spinner")
 		os.Exit(1)
@@ -74,7 +135,12 @@ func runReleaseCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	cl, err := changelog.HandleChangelog(newRelease.ProjectName, newRelease.Version, newRelease.Date, spinner)
+	var cl []byte
+	if dryRun {
+		cl, err = changelog.PreviewChangelog(newRelease.ProjectName, newRelease.Version, newRelease.Date)
+	} else {
+		cl, err = changelog.HandleChangelog(newRelease.ProjectName, newRelease.Version, newRelease.Date, spinner)
+	}
 	if err != nil {
 		spinner.StopFailMessage(fmt.Sprintf("%v", err))
 		spinner.StopFail()
@@ -84,16 +150,66 @@ func runReleaseCmd(cmd *cobra.Command, args []string) {
 
 	newRelease.Changelog = cl
 
-	var binaryPath string
+	// the changelog file is checked before anything is built or published so a version it already
+	// has an entry for stops the release up front; it is only written once the release exists
+	var changelogContents []byte
+	if changelogFile != "" {
+		changelogContents, err = changelog.UpdatedChangelogFile(changelogFile, newRelease.Version, cl)
+		if err != nil {
+			spinner.StopFailMessage(fmt.Sprintf("could not update changelog file: %v", err))
+			spinner.StopFail()
+			os.Exit(1)
+			return
+		}
+	}
+
+	var assets []github.Asset
 	skipBinary, _ := cmd.Flags().GetBool("skipBinary")
 	if !skipBinary {
 		// set project build path so we have a predictable location
-		binaryPath = fmt.Sprintf(binaryPathFmt, newRelease.ProjectName, newRelease.Version)
-		runBuildCmd(cmd, []string{newRelease.Version, binaryPath})
+		binaryPath := fmt.Sprintf(binaryPathFmt, newRelease.ProjectName, newRelease.Version)
+
+		spinner.Message("Building")
+		assets, err = buildTargets(cmd, []string{newRelease.Version, binaryPath})
+		if err != nil {
+			spinner.StopFailMessage(fmt.Sprintf("%v", err))
+			spinner.StopFail()
+			os.Exit(1)
+			return
+		}
+	}
+
+	extraAssets, err := globAssets(projectRoot, config.Release.Assets)
+	if err != nil {
+		spinner.StopFailMessage(fmt.Sprintf("%v", err))
+		spinner.StopFail()
+		os.Exit(1)
+		return
+	}
+	assets = append(assets, extraAssets...)
+
+	if dryRun {
+		assets = newRelease.PlanPackageAssets(assets, config.Release)
+		newRelease.PlanGithubRelease(assets)
+		if changelogFile != "" {
+			utils.PrintPlan("changelog", fmt.Sprintf("insert v%s into %s", newRelease.Version, changelogFile))
+		}
+		spinner.Suffix(" Finished release plan")
+		spinner.Stop()
+		return
+	}
+
+	spinner.Message("Packaging assets")
+	assets, err = newRelease.PackageAssets(assets, config.Release)
+	if err != nil {
+		spinner.StopFailMessage(fmt.Sprintf("%v", err))
+		spinner.StopFail()
+		os.Exit(1)
+		return
 	}
 
 	tokenFile, _ := cmd.Flags().GetString("tokenFile")
-	err = newRelease.CreateGithubRelease(tokenFile, binaryPath, spinner)
+	err = newRelease.CreateGithubRelease(tokenFile, assets, spinner)
 	if err != nil {
 		spinner.StopFailMessage(fmt.Sprintf("%v", err))
 		spinner.StopFail()
@@ -101,12 +217,105 @@ func runReleaseCmd(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// the changelog file is only written once the release exists so a failed release
+	// doesn't leave behind an entry for a version that was never published
+	if changelogFile != "" {
+		spinner.Message(fmt.Sprintf("Updating %s", config.Changelog))
+		err = ioutil.WriteFile(changelogFile, changelogContents, 0644)
+		if err != nil {
+			spinner.StopFailMessage(fmt.Sprintf("could not update changelog file: %v", err))
+			spinner.StopFail()
+			os.Exit(1)
+			return
+		}
+	}
+
 	spinner.Suffix(" Finished release")
 	spinner.Stop()
 }
 
+// globAssets returns a release asset for every file matching the patterns given, which are
+// relative to the project root. Each asset is named after its file
+func globAssets(projectRoot string, patterns []string) ([]github.Asset, error) {
+	var assets []github.Asset
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(projectPath(projectRoot, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse asset pattern %q: %w", pattern, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("asset pattern %q did not match any files", pattern)
+		}
+
+		for _, match := range matches {
+			assets = append(assets, github.Asset{
+				Name: filepath.Base(match),
+				Path: match,
+			})
+		}
+	}
+
+	return assets, nil
+}
+
+// projectPath returns a path from the config file joined to the project root unless it is absolute
+func projectPath(projectRoot, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(projectRoot, path)
+}
+
+// promptVersion looks up the latest version tag and asks the user to choose between
+// the next patch, minor and major versions. The prompt is written to output so it stays
+// separate from any dry run plan printed to stdout
+func promptVersion(input io.Reader, output io.Writer) (string, error) {
+	latest, err := github.LatestVersion()
+	if err != nil {
+		return "", err
+	}
+
+	if latest == nil {
+		latest, _ = semver.NewVersion("0.0.0")
+		fmt.Fprintln(output, "No previous version tags found")
+	} else {
+		fmt.Fprintf(output, "Latest version is v%s\n", latest)
+	}
+
+	candidates := []semver.Version{latest.IncPatch(), latest.IncMinor(), latest.IncMajor()}
+	fmt.Fprintf(output, "  1) patch v%s\n", &candidates[0])
+	fmt.Fprintf(output, "  2) minor v%s\n", &candidates[1])
+	fmt.Fprintf(output, "  3) major v%s\n", &candidates[2])
+	fmt.Fprint(output, "Select next version [1]: ")
+
+	reader := bufio.NewReader(input)
+	choice, err := reader.ReadString('\n')
+	if err == io.EOF {
+		// only an empty line picks the default; running out of input is not an answer
+		return "", fmt.Errorf("no version selected")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	choice = strings.TrimSpace(choice)
+	if choice == "" {
+		choice = "1"
+	}
+
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(candidates) {
+		return "", fmt.Errorf("invalid selection %q", choice)
+	}
+
+	return candidates[index-1].String(), nil
+}
+
 func init() {
-	cmdRelease.Flags().Bool("skipBinary", false, "don't add a build asset for this release")
+	cmdRelease.Flags().Bool("skipBinary", false, "don't add build assets for this release")
 	cmdRelease.Flags().StringP("tokenFile", "t", "", "github api key file (default is $HOME/.github_token)")
 
 	rootCmd.AddCommand(cmdRelease)
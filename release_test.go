@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver"
+	"github.com/clintjedwards/toolkit/github"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGlobAssets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolkit_release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "dist"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "dist", "toolkit.1"), []byte("manual"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "LICENSE"), []byte("license"), 0644)
+
+	// relative patterns are matched from the project root regardless of the working directory
+	assets, err := globAssets(dir, []string{"dist/*.1", filepath.Join(dir, "LICENSE")})
+	if err != nil {
+		t.Fatalf("could not glob assets: %v", err)
+	}
+
+	expected := []github.Asset{
+		{Name: "toolkit.1", Path: filepath.Join(dir, "dist", "toolkit.1")},
+		{Name: "LICENSE", Path: filepath.Join(dir, "LICENSE")},
+	}
+	if !cmp.Equal(expected, assets) {
+		t.Errorf("assets are not as expected; Diff below: \n%v", cmp.Diff(expected, assets))
+	}
+
+	_, err = globAssets(dir, []string{"*.tar.gz"})
+	if err == nil {
+		t.Errorf("expected an error for a pattern that matches no files")
+	}
+}
+
+func TestPromptVersion(t *testing.T) {
+	// an empty line picks the next patch version
+	version, err := promptVersion(strings.NewReader("\n"), ioutil.Discard)
+	if err != nil {
+		t.Fatalf("expected the default version for an empty line; got %v", err)
+	}
+	if _, err := semver.NewVersion(version); err != nil {
+		t.Errorf("expected a semver version; got %q", version)
+	}
+
+	// running out of input, as from /dev/null or a closed pipe, is not an answer
+	_, err = promptVersion(strings.NewReader(""), ioutil.Discard)
+	if err == nil {
+		t.Errorf("expected an error when there is no input")
+	}
+
+	_, err = promptVersion(strings.NewReader("4\n"), ioutil.Discard)
+	if err == nil {
+		t.Errorf("expected an error for an invalid selection")
+	}
+}
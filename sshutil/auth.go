@@ -0,0 +1,232 @@
+package sshutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const agentSocketEnv string = "SSH_AUTH_SOCK"
+
+// defaultKeyFiles are the private keys looked for in ~/.ssh when no identity file is configured
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// decryptedKeys caches passphrase protected keys once they have been decrypted so the user is
+// only prompted once per key even when connecting to many hosts
+var decryptedKeys = struct {
+	sync.Mutex
+	signers map[string]ssh.Signer
+}{signers: map[string]ssh.Signer{}}
+
+// auth gathers the signers available to authenticate with
+type auth struct {
+	agentConn net.Conn
+	signers   []ssh.Signer
+}
+
+// newAuth collects signers from the ssh agent, if one is running, followed by the identity file given
+// if identityFile is empty the default key files in ~/.ssh that can be parsed are used instead
+// Caller should remember to close auth once the connection is established
+func newAuth(identityFile string) (*auth, error) {
+	a := &auth{}
+
+	// like ssh, an agent that can't be reached is skipped in favor of key files
+	socket := os.Getenv(agentSocketEnv)
+	if socket != "" {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			a.agentConn = conn
+
+			signers, err := agent.NewClient(conn).Signers()
+			if err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("could not get keys from ssh agent: %w", err)
+			}
+			a.signers = append(a.signers, signers...)
+		}
+	}
+
+	keyFiles, err := getKeyFiles(identityFile)
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	// like ssh, default key files that can't be used are skipped so keys from the agent still work
+	var skipped []string
+	for _, keyFile := range keyFiles {
+		signer, err := getKeySigner(keyFile)
+		if err != nil && identityFile != "" {
+			a.Close()
+			return nil, err
+		}
+		if err != nil {
+			skipped = append(skipped, err.Error())
+			continue
+		}
+		a.signers = append(a.signers, signer)
+	}
+
+	if len(a.signers) == 0 {
+		a.Close()
+		if len(skipped) != 0 {
+			return nil, fmt.Errorf("no usable ssh keys found; start an ssh agent or configure an identity file: %s",
+				strings.Join(skipped, "; "))
+		}
+		return nil, fmt.Errorf("no ssh keys found; start an ssh agent or configure an identity file")
+	}
+
+	return a, nil
+}
+
+// method returns an ssh auth method that tries every signer in order
+func (a *auth) method() ssh.AuthMethod {
+	return ssh.PublicKeys(a.signers...)
+}
+
+// Close closes the connection to the ssh agent if there is one
+func (a *auth) Close() error {
+	if a.agentConn == nil {
+		return nil
+	}
+
+	return a.agentConn.Close()
+}
+
+// getKeyFiles returns the private key files to authenticate with. An explicitly configured
+// identity file must exist; default key files are skipped if they don't
+func getKeyFiles(identityFile string) ([]string, error) {
+	if identityFile != "" {
+		path, err := homedir.Expand(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not expand identity file path: %w", err)
+		}
+
+		_, err = os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not get ssh key from: %s; %w", path, err)
+		}
+
+		return []string{path}, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	var keyFiles []string
+	for _, name := range defaultKeyFiles {
+		path := filepath.Join(home, ".ssh", name)
+
+		_, err = os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		keyFiles = append(keyFiles, path)
+	}
+
+	return keyFiles, nil
+}
+
+// getKeySigner parses a private key file of any type supported by the ssh package
+// passphrase protected keys are only decrypted once the server accepts their public key
+func getKeySigner(keyFile string) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ssh key %s: %w", keyFile, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err == nil {
+		return signer, nil
+	}
+
+	var passphraseErr *ssh.PassphraseMissingError
+	if !errors.As(err, &passphraseErr) {
+		return nil, fmt.Errorf("could not parse ssh key %s: %w", keyFile, err)
+	}
+
+	public := passphraseErr.PublicKey
+	if public == nil {
+		// older key formats don't include the public key so fall back to the .pub file
+		publicFile, err := ioutil.ReadFile(keyFile + ".pub")
+		if err != nil {
+			return nil, fmt.Errorf("could not read public key for passphrase protected key %s: %w", keyFile, err)
+		}
+
+		public, _, _, _, err = ssh.ParseAuthorizedKey(publicFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse public key %s.pub: %w", keyFile, err)
+		}
+	}
+
+	return &encryptedSigner{
+		path:   keyFile,
+		key:    key,
+		public: public,
+	}, nil
+}
+
+// encryptedSigner is a passphrase protected key that prompts for its passphrase the first time it is used
+type encryptedSigner struct {
+	path   string
+	key    []byte
+	public ssh.PublicKey
+}
+
+func (s *encryptedSigner) PublicKey() ssh.PublicKey {
+	return s.public
+}
+
+func (s *encryptedSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	signer, err := s.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	return signer.Sign(rand, data)
+}
+
+// decrypt prompts the user for the key's passphrase unless it has already been decrypted
+func (s *encryptedSigner) decrypt() (ssh.Signer, error) {
+	decryptedKeys.Lock()
+	defer decryptedKeys.Unlock()
+
+	signer, ok := decryptedKeys.signers[s.path]
+	if ok {
+		return signer, nil
+	}
+
+	stdin := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(stdin) {
+		return nil, fmt.Errorf("ssh key %s is passphrase protected and there is no terminal to prompt on; "+
+			"add it to an ssh agent instead", s.path)
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter passphrase for key '%s': ", s.path)
+	passphrase, err := terminal.ReadPassword(stdin)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("could not read passphrase: %w", err)
+	}
+
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(s.key, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt ssh key %s: %w", s.path, err)
+	}
+
+	decryptedKeys.signers[s.path] = signer
+	return signer, nil
+}
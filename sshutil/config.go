@@ -0,0 +1,172 @@
+package sshutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// sshConfig is a parsed OpenSSH client config file (~/.ssh/config)
+// only the settings needed to reach a host are supported: HostName, User, Port, IdentityFile and ProxyJump
+type sshConfig struct {
+	sections []configSection
+}
+
+// configSection is a Host block and the settings that apply to hosts matching its patterns
+type configSection struct {
+	patterns []string
+	settings map[string]string // keyed by lowercase setting name
+}
+
+// hostConfig contains the settings resolved for a single host
+type hostConfig struct {
+	HostName     string
+	User         string
+	Port         string
+	IdentityFile string
+	ProxyJump    string
+}
+
+// loadSSHConfig reads an ssh config file; a file that does not exist results in an empty config
+func loadSSHConfig(path string) (*sshConfig, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &sshConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open ssh config: %w", err)
+	}
+	defer file.Close()
+
+	config, err := parseSSHConfig(bufio.NewScanner(file))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse ssh config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+func parseSSHConfig(scanner *bufio.Scanner) (*sshConfig, error) {
+	// settings before the first Host line apply to every host
+	current := configSection{patterns: []string{"*"}, settings: map[string]string{}}
+	config := &sshConfig{}
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value := splitConfigLine(line)
+		if value == "" {
+			return nil, fmt.Errorf("line %d: missing value for %s", lineNum, key)
+		}
+
+		switch strings.ToLower(key) {
+		case "host":
+			config.sections = append(config.sections, current)
+			current = configSection{patterns: strings.Fields(value), settings: map[string]string{}}
+		case "match":
+			// match blocks aren't supported so their settings are never applied
+			config.sections = append(config.sections, current)
+			current = configSection{settings: map[string]string{}}
+		default:
+			// the first value given for a setting within a section wins
+			if _, ok := current.settings[strings.ToLower(key)]; !ok {
+				current.settings[strings.ToLower(key)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+
+	config.sections = append(config.sections, current)
+
+	return config, scanner.Err()
+}
+
+// splitConfigLine splits a config line into its keyword and arguments
+// keywords and arguments may be separated by whitespace or an optional '='
+func splitConfigLine(line string) (key, value string) {
+	index := strings.IndexAny(line, " \t=")
+	if index == -1 {
+		return line, ""
+	}
+
+	key = line[:index]
+	value = strings.TrimLeft(line[index:], " \t")
+	value = strings.TrimPrefix(value, "=")
+
+	return key, strings.TrimSpace(value)
+}
+
+// resolve returns the settings for a host alias; like ssh, the first value found for each setting is used
+func (c *sshConfig) resolve(alias string) hostConfig {
+	settings := map[string]string{}
+
+	for _, section := range c.sections {
+		if !matchHost(section.patterns, alias) {
+			continue
+		}
+
+		for key, value := range section.settings {
+			if _, ok := settings[key]; !ok {
+				settings[key] = value
+			}
+		}
+	}
+
+	resolved := hostConfig{
+		HostName:     settings["hostname"],
+		User:         settings["user"],
+		Port:         settings["port"],
+		IdentityFile: settings["identityfile"],
+		ProxyJump:    settings["proxyjump"],
+	}
+
+	if resolved.HostName == "" {
+		resolved.HostName = alias
+	}
+	resolved.HostName = strings.ReplaceAll(resolved.HostName, "%h", alias)
+
+	if resolved.IdentityFile != "" {
+		home, _ := homedir.Dir()
+		identityFile := strings.NewReplacer("%d", home, "%h", resolved.HostName, "%%", "%").Replace(resolved.IdentityFile)
+		resolved.IdentityFile, _ = homedir.Expand(identityFile)
+	}
+
+	if strings.EqualFold(resolved.ProxyJump, "none") {
+		resolved.ProxyJump = ""
+	}
+
+	return resolved
+}
+
+// matchHost reports whether a host matches a list of Host patterns
+// a matching negated pattern (!pattern) excludes the host regardless of other patterns
+func matchHost(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	matched := false
+
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+
+		ok, err := filepath.Match(pattern, host)
+		if err != nil || !ok {
+			continue
+		}
+
+		if negated {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+}
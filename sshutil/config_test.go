@@ -0,0 +1,49 @@
+package sshutil
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveSSHConfig(t *testing.T) {
+	config, err := parseSSHConfig(bufio.NewScanner(strings.NewReader(`
+# bastion for production
+Host bastion
+  HostName bastion.example.com
+  User jump
+
+Host prod-* !prod-legacy
+  HostName %h.internal
+  Port=2222
+  ProxyJump bastion
+
+Host *
+  User deploy
+  Port 22
+  IdentityFile /keys/id_ed25519
+`)))
+	if err != nil {
+		t.Fatalf("could not parse ssh config: %v", err)
+	}
+
+	expected := hostConfig{
+		HostName:     "prod-web.internal",
+		User:         "deploy",
+		Port:         "2222",
+		IdentityFile: "/keys/id_ed25519",
+		ProxyJump:    "bastion",
+	}
+
+	resolved := config.resolve("prod-web")
+	if !cmp.Equal(expected, resolved) {
+		t.Errorf("resolved host does not match expected; Diff below: \n%v", cmp.Diff(expected, resolved))
+	}
+
+	legacy := config.resolve("prod-legacy")
+	if legacy.HostName != "prod-legacy" || legacy.Port != "22" || legacy.ProxyJump != "" {
+		t.Errorf("negated pattern should not apply to prod-legacy; got %+v", legacy)
+	}
+}
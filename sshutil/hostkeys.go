@@ -0,0 +1,199 @@
+package sshutil
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// HostKeyCheckStrict refuses to connect to any host whose key is not already in a known hosts file
+	HostKeyCheckStrict string = "strict"
+	// HostKeyCheckTOFU prompts the user to trust unknown hosts on first use and records their keys
+	HostKeyCheckTOFU string = "tofu"
+)
+
+// promptMutex makes sure only one prompt is shown to the user at a time
+var promptMutex sync.Mutex
+
+// hostKeys verifies server host keys against known hosts files
+// it is safe to share between connections; only one unknown host prompt is shown at a time
+type hostKeys struct {
+	mutex    sync.Mutex
+	files    []string // known hosts files to check; files that don't exist yet are skipped
+	recordTo string   // file new keys are recorded in
+	mode     string
+	callback ssh.HostKeyCallback
+}
+
+// newHostKeys loads the known hosts files given. Files that don't exist are skipped, apart
+// from the first which is created if a new key needs to be recorded to it
+func newHostKeys(files []string, mode string) (*hostKeys, error) {
+	if mode == "" {
+		mode = HostKeyCheckTOFU
+	}
+
+	if mode != HostKeyCheckStrict && mode != HostKeyCheckTOFU {
+		return nil, fmt.Errorf("unknown host key check mode %q; must be one of: %s, %s",
+			mode, HostKeyCheckStrict, HostKeyCheckTOFU)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no known hosts files given")
+	}
+
+	h := &hostKeys{
+		files:    files,
+		recordTo: files[0],
+		mode:     mode,
+	}
+
+	err := h.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// load reads the known hosts files that exist into the callback used to check keys
+func (h *hostKeys) load() error {
+	var existing []string
+	for _, file := range h.files {
+		_, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		existing = append(existing, file)
+	}
+
+	if len(existing) == 0 {
+		h.callback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}
+		return nil
+	}
+
+	callback, err := knownhosts.New(existing...)
+	if err != nil {
+		return fmt.Errorf("could not read known hosts files: %w", err)
+	}
+	h.callback = callback
+
+	return nil
+}
+
+// algorithms returns the host key algorithms already known for an address so the server
+// is asked for a key we can verify rather than one of its choosing
+func (h *hostKeys) algorithms(address string) []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// checking a throwaway key returns an error listing every known key for the address
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+
+	probe, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	err = h.callback(address, &net.TCPAddr{}, probe)
+	if !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		algorithms = append(algorithms, known.Key.Type())
+	}
+
+	return algorithms
+}
+
+// check is a ssh.HostKeyCallback that verifies the key presented by a server
+func (h *hostKeys) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	err := h.callback(hostname, remote, key)
+	if err == nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+
+	if len(keyErr.Want) != 0 {
+		return fmt.Errorf("host key for %s does not match the key in %s:%d; "+
+			"the host may have been reinstalled or the connection is being intercepted",
+			hostname, keyErr.Want[0].Filename, keyErr.Want[0].Line)
+	}
+
+	if h.mode == HostKeyCheckStrict {
+		return fmt.Errorf("host %s (%s %s) is not in any known hosts file and host key checking is strict",
+			hostname, key.Type(), ssh.FingerprintSHA256(key))
+	}
+
+	return h.trust(hostname, key)
+}
+
+// trust asks the user whether to trust an unknown host and records its key if they do
+func (h *hostKeys) trust(hostname string, key ssh.PublicKey) error {
+	// connections made in parallel share stdin so only one may prompt at a time
+	promptMutex.Lock()
+	defer promptMutex.Unlock()
+
+	// another connection may have recorded the key while we were waiting to prompt
+	err := h.load()
+	if err != nil {
+		return err
+	}
+	if h.callback(hostname, &net.TCPAddr{}, key) == nil {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\n", hostname)
+	fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+	fmt.Fprint(os.Stderr, "Are you sure you want to continue connecting (yes/no)? ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if strings.TrimSpace(strings.ToLower(answer)) != "yes" {
+		return fmt.Errorf("host key for %s was not trusted", hostname)
+	}
+
+	err = os.MkdirAll(filepath.Dir(h.recordTo), 0700)
+	if err != nil {
+		return fmt.Errorf("could not create known hosts directory: %w", err)
+	}
+
+	file, err := os.OpenFile(h.recordTo, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open known hosts file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	if err != nil {
+		return fmt.Errorf("could not record host key: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Permanently added '%s' to %s\n", hostname, h.recordTo)
+
+	return h.load()
+}
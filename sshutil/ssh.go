@@ -1,10 +1,19 @@
 package sshutil
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"golang.org/x/crypto/ssh"
@@ -12,105 +21,489 @@ import (
 
 //https://zaiste.net/executing_commands_via_ssh_using_go/
 
-const port string = "22"
+const defaultPort string = "22"
 
-func getKeyAuth(keyfile string) ssh.AuthMethod {
-	key, err := ioutil.ReadFile(keyfile)
+// Options controls how connections to servers are established and verified
+type Options struct {
+	// KnownHostsFiles are checked for server host keys. New keys trusted on first use
+	// are recorded in the first file
+	KnownHostsFiles []string
+	// HostKeyCheck is either HostKeyCheckStrict or HostKeyCheckTOFU; defaults to HostKeyCheckTOFU
+	HostKeyCheck string
+	// IdentityFile is the private key to authenticate with alongside any keys in the ssh agent
+	// if empty the IdentityFile from the ssh config is used, falling back to the default keys
+	// in ~/.ssh: id_ed25519, id_ecdsa and id_rsa
+	IdentityFile string
+	// ConfigFile is the ssh config file used to resolve host aliases; defaults to ~/.ssh/config
+	ConfigFile string
+	// JumpHosts are connected through in order, in form [user@]host[:port], before connecting to
+	// the destination. Overrides any ProxyJump setting from the ssh config
+	JumpHosts []string
+}
+
+// Client is a connection to a single ssh server that can be used to both upload files
+// and run commands without having to authenticate more than once
+// Caller should remember to close the client
+type Client struct {
+	// Stdout and Stderr receive the output of remote commands; default to os.Stdout and os.Stderr
+	Stdout io.Writer
+	Stderr io.Writer
+
+	client   *ssh.Client
+	jumps    []*ssh.Client // connections to jump hosts the client is tunneled through
+	hostname string
+}
+
+// endpoint is a fully resolved server to connect to
+type endpoint struct {
+	user         string
+	address      string // host:port
+	identityFile string
+}
+
+// connect to specified ssh server, tunneling through the client given if it is not nil;
+// authenticates using keys from the ssh agent and identity file, then verifies the server
+// against known hosts
+// Caller should remember to close client
+func connect(server endpoint, through *ssh.Client, hostKeys *hostKeys) (client *ssh.Client, err error) {
+	auth, err := newAuth(server.identityFile)
+	if err != nil {
+		return nil, err
+	}
+	defer auth.Close()
+
+	config := &ssh.ClientConfig{
+		User: server.user,
+		Auth: []ssh.AuthMethod{
+			auth.method(),
+		},
+		HostKeyCallback:   hostKeys.check,
+		HostKeyAlgorithms: hostKeys.algorithms(server.address),
+	}
+
+	if through == nil {
+		client, err = ssh.Dial("tcp", server.address, config)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to server %s: %w", server.address, err)
+		}
+
+		return client, nil
+	}
+
+	conn, err := through.Dial("tcp", server.address)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("could not open tunnel to %s: %w", server.address, err)
 	}
-	signer, err := ssh.ParsePrivateKey(key)
+
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, server.address, config)
 	if err != nil {
-		panic(err)
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to server %s: %w", server.address, err)
 	}
-	return ssh.PublicKeys(signer)
+
+	return ssh.NewClient(clientConn, chans, reqs), nil
 }
 
-// connect to specified ssh server; attempts to use ssh-key auth by looking for
-// default $HOME/.ssh/id_rsa keyfile location
-// Caller should remember to close client and session
-func connect(user, host string) (session *ssh.Session, client *ssh.Client, err error) {
+// Dial establishes a connection with the server provided in form [user@]host[:port]
+// The host may be an alias from the ssh config file, in which case its HostName, User, Port,
+// IdentityFile and ProxyJump settings are honored
+func Dial(hostname string, opts Options) (*Client, error) {
+	hostKeys, err := newHostKeys(opts.KnownHostsFiles, opts.HostKeyCheck)
+	if err != nil {
+		return nil, err
+	}
 
-	home, err := homedir.Dir()
+	sshConfig, err := opts.sshConfig()
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	sshKeyPathFmt := "%s/%s/%s"
-	sshKeyPath := fmt.Sprintf(sshKeyPathFmt, home, ".ssh", "id_rsa")
+	destination, jumps, err := resolve(sshConfig, hostname, opts.IdentityFile, opts.JumpHosts)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err = os.Stat(sshKeyPath)
-	if os.IsNotExist(err) {
-		return nil, nil, fmt.Errorf("could not get ssh key from: %s", sshKeyPath)
+	client := &Client{
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		hostname: hostname,
 	}
 
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			getKeyAuth(sshKeyPath),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	var through *ssh.Client
+	for _, jump := range jumps {
+		through, err = connect(jump, through, hostKeys)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("could not connect to jump host: %w", err)
+		}
+		client.jumps = append(client.jumps, through)
 	}
 
-	client, err = ssh.Dial("tcp", fmt.Sprintf("%s:%s", host, port), config)
+	client.client, err = connect(destination, through, hostKeys)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not connect to server: %w", err)
+		client.Close()
+		return nil, fmt.Errorf("could not create connection: %w", err)
 	}
 
-	// A session helps us run multiple commands in a single connection
-	session, err = client.NewSession()
+	return client, nil
+}
+
+// ResolveHostname returns the real hostname of a destination in form [user@]host[:port], after
+// applying any HostName setting from the ssh config file. This is the name the server is
+// connected to by, without the user or port
+func ResolveHostname(destination string, opts Options) (string, error) {
+	sshConfig, err := opts.sshConfig()
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not establish session: %w", err)
+		return "", err
 	}
 
-	return session, client, nil
-}
+	_, host, _ := SplitDestination(destination)
+	if host == "" {
+		return "", fmt.Errorf("host %q not in correct format: [user@]host[:port]", destination)
+	}
 
-// RunCommandsOverSSH establishes a connection with server provided and inputs commands
-// in a single session
-func RunCommandsOverSSH(hostname string, commands []string) error {
+	return sshConfig.resolve(host).HostName, nil
+}
 
-	// We need some way to tell the server we no longer want to send commands
-	// and exit the session
-	commands = append(commands, "exit")
+// sshConfig loads the ssh config file the options name, defaulting to ~/.ssh/config
+func (opts Options) sshConfig() (*sshConfig, error) {
+	configFile := opts.ConfigFile
+	if configFile == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		configFile = filepath.Join(home, ".ssh", "config")
+	}
 
-	hostParts := strings.Split(hostname, "@")
+	return loadSSHConfig(configFile)
+}
 
-	session, client, err := connect(hostParts[0], hostParts[1])
+// resolve uses the ssh config to turn a destination in form [user@]host[:port] into the endpoint
+// to connect to and any jump hosts that need to be connected through first, in order.
+// Jump hosts given take precedence over the destination's ProxyJump setting
+func resolve(sshConfig *sshConfig, destination, identityFile string, jumpHosts []string) (endpoint, []endpoint, error) {
+	server, proxyJump, err := resolveEndpoint(sshConfig, destination)
 	if err != nil {
-		return fmt.Errorf("could not create connection: %w", err)
+		return endpoint{}, nil, err
+	}
+
+	if identityFile != "" {
+		server.identityFile = identityFile
+	}
+
+	if len(jumpHosts) == 0 && proxyJump != "" {
+		jumpHosts = strings.Split(proxyJump, ",")
+	}
+
+	var jumps []endpoint
+	for _, jumpHost := range jumpHosts {
+		// like ssh, only the ProxyJump of the destination is followed
+		jump, _, err := resolveEndpoint(sshConfig, strings.TrimSpace(jumpHost))
+		if err != nil {
+			return endpoint{}, nil, fmt.Errorf("could not resolve jump host: %w", err)
+		}
+		jumps = append(jumps, jump)
+	}
+
+	return server, jumps, nil
+}
+
+// resolveEndpoint applies ssh config settings to a single [user@]host[:port] destination; values
+// given in the destination itself take precedence. Returns the endpoint and its ProxyJump setting
+func resolveEndpoint(sshConfig *sshConfig, destination string) (endpoint, string, error) {
+	user, host, port := SplitDestination(destination)
+	if host == "" {
+		return endpoint{}, "", fmt.Errorf("host %q not in correct format: [user@]host[:port]", destination)
+	}
+
+	hostConfig := sshConfig.resolve(host)
+
+	if user == "" {
+		user = hostConfig.User
+	}
+	if user == "" {
+		currentUser, err := getCurrentUser()
+		if err != nil {
+			return endpoint{}, "", fmt.Errorf("could not determine user for %s: %w", destination, err)
+		}
+		user = currentUser
+	}
+
+	if port == "" {
+		port = hostConfig.Port
+	}
+	if port == "" {
+		port = defaultPort
+	}
+
+	return endpoint{
+		user:         user,
+		address:      net.JoinHostPort(hostConfig.HostName, port),
+		identityFile: hostConfig.IdentityFile,
+	}, hostConfig.ProxyJump, nil
+}
+
+// SplitDestination breaks [user@]host[:port] into its parts; missing parts are returned empty
+func SplitDestination(destination string) (user, host, port string) {
+	host = destination
+
+	if index := strings.LastIndex(host, "@"); index != -1 {
+		user = host[:index]
+		host = host[index+1:]
+	}
+
+	if splitHost, splitPort, err := net.SplitHostPort(host); err == nil {
+		host = splitHost
+		port = splitPort
+	}
+
+	return user, host, port
+}
+
+// getCurrentUser returns the name of the local user, which ssh uses when no user is configured
+func getCurrentUser() (string, error) {
+	current, err := user.Current()
+	if err == nil {
+		return current.Username, nil
 	}
 
+	name := os.Getenv("USER")
+	if name == "" {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// Close closes the underlying ssh connection and any jump host connections it is tunneled through
+func (c *Client) Close() error {
+	var err error
+	if c.client != nil {
+		err = c.client.Close()
+	}
+
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		c.jumps[i].Close()
+	}
+
+	return err
+}
+
+// RunCommandsOverSSH establishes a connection with server provided and runs each command
+// stopping at the first command that fails
+func RunCommandsOverSSH(hostname string, commands []string, opts Options) error {
+	client, err := Dial(hostname, opts)
+	if err != nil {
+		return err
+	}
 	defer client.Close()
-	defer session.Close()
 
-	// StdinPipe for commands
-	stdin, err := session.StdinPipe()
+	return client.RunCommands(commands)
+}
+
+// CommandError is returned when a remote command exits with a non-zero status
+type CommandError struct {
+	Command    string
+	ExitStatus int    // -1 if the server did not report an exit status
+	Output     []byte // combined stdout and stderr of the command
+}
+
+// maxErrorOutputLines limits how much command output is included in error messages
+const maxErrorOutputLines = 10
+
+func (e *CommandError) Error() string {
+	message := fmt.Sprintf("command '%s' exited with status %d", e.Command, e.ExitStatus)
+	if e.ExitStatus == -1 {
+		message = fmt.Sprintf("command '%s' exited without reporting a status", e.Command)
+	}
+
+	output := strings.TrimSpace(string(e.Output))
+	if output == "" {
+		return message
+	}
+
+	lines := strings.Split(output, "\n")
+	if len(lines) > maxErrorOutputLines {
+		lines = lines[len(lines)-maxErrorOutputLines:]
+	}
+
+	return fmt.Sprintf("%s; output:\n%s", message, strings.Join(lines, "\n"))
+}
+
+// stepDir is where RunCommands records the failing command on the host, relative to the
+// user's home directory
+const stepDir = ".toolkit"
+
+// RunCommands runs the commands in order in a single shell that stops at the first command that
+// fails, as if they were lines of a script run with "set -e". Shell state such as the working
+// directory and exported variables carries over from one command to the next.
+// A *CommandError naming the command that failed is returned if the shell exits with a non-zero status
+func (c *Client) RunCommands(commands []string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	// the shell records which command it was running when it exits with an error so the
+	// failing command can be reported; the file is only written if a command fails. It is kept in
+	// the user's own home directory since a predictable path in /tmp could be planted by other users
+	stepFile := fmt.Sprintf("$HOME/%s/step_%d", stepDir, time.Now().UnixNano())
+
+	err := c.run(commandScript(commands, stepFile), c.Stdout, c.Stderr, 0)
+
+	var commandErr *CommandError
+	if !errors.As(err, &commandErr) {
+		return err
+	}
+
+	commandErr.Command = strings.Join(commands, "; ")
+	output, stepErr := c.Output(fmt.Sprintf(`cat "%s" && rm -f "%s"`, stepFile, stepFile))
+	if stepErr != nil {
+		return commandErr
+	}
+
+	step, stepErr := strconv.Atoi(strings.TrimSpace(string(output)))
+	if stepErr == nil && step >= 0 && step < len(commands) {
+		commandErr.Command = commands[step]
+	}
+
+	return commandErr
+}
+
+// commandScript returns a shell script that runs the commands given in order, stopping at the first
+// that fails and writing the index of the failing command to stepFile
+func commandScript(commands []string, stepFile string) string {
+	var script strings.Builder
+
+	script.WriteString("set -e\n")
+	fmt.Fprintf(&script, "trap '__toolkit_status=$?; if [ $__toolkit_status -ne 0 ]; then "+
+		"mkdir -p \"%s\" && echo $__toolkit_step > \"%s\"; fi' EXIT\n", path.Dir(stepFile), stepFile)
+	for i, command := range commands {
+		fmt.Fprintf(&script, "__toolkit_step=%d\n%s\n", i, command)
+	}
+
+	return script.String()
+}
+
+// RunCommand runs a single command in a new session, streaming its output to the client's
+// Stdout and Stderr. Returns a *CommandError if the command exits with a non-zero status
+func (c *Client) RunCommand(command string) error {
+	return c.run(command, c.Stdout, c.Stderr, 0)
+}
+
+// Output runs a single command in a new session and returns its standard output instead of
+// streaming it. Returns a *CommandError if the command exits with a non-zero status
+func (c *Client) Output(command string) ([]byte, error) {
+	return c.OutputTimeout(command, 0)
+}
+
+// OutputTimeout is Output with a limit on how long the command may run. The session is closed,
+// ending the command, if it has not finished within the timeout; a timeout of 0 means no limit
+func (c *Client) OutputTimeout(command string, timeout time.Duration) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := c.run(command, &stdout, ioutil.Discard, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Dial opens a connection to the address given from the server, the same way ssh -L does, so
+// addresses that are only reachable from the server can be connected to
+func (c *Client) Dial(network, address string) (net.Conn, error) {
+	return c.client.Dial(network, address)
+}
+
+// DialTimeout is Dial with a limit on how long opening the connection may take
+func (c *Client) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		conn, err := c.Dial(network, address)
+		results <- result{conn: conn, err: err}
+	}()
+
+	select {
+	case result := <-results:
+		return result.conn, result.err
+	case <-time.After(timeout):
+		// the dial can't be cancelled; close the connection if it is opened after all
+		go func() {
+			result := <-results
+			if result.conn != nil {
+				result.conn.Close()
+			}
+		}()
+		return nil, fmt.Errorf("could not connect to %s: timed out after %s", address, timeout)
+	}
+}
+
+// run runs a command in a new session, ending it if it is still running after the timeout given;
+// a timeout of 0 means no limit
+func (c *Client) run(command string, stdout, stderr io.Writer, timeout time.Duration) error {
+	session, err := c.client.NewSession()
 	if err != nil {
-		return fmt.Errorf("could not get stdin pipe: %w", err)
+		return fmt.Errorf("could not establish session: %w", err)
 	}
+	defer session.Close()
 
-	session.Stdout = os.Stdout
-	session.Stderr = os.Stderr
+	// output is captured as well as streamed so it can be reported if the command fails
+	var output bytes.Buffer
+	session.Stdout = io.MultiWriter(stdout, &output)
+	session.Stderr = io.MultiWriter(stderr, &output)
 
-	// Start remote shell
-	err = session.Shell()
+	err = session.Start(command)
 	if err != nil {
-		return fmt.Errorf("could not start remote shell: %w", err)
+		return fmt.Errorf("could not run command '%s': %w", command, err)
 	}
 
-	for _, cmd := range commands {
-		_, err = fmt.Fprintf(stdin, "%s\n", cmd)
-		if err != nil {
-			return fmt.Errorf("could not run command '%s': %w", cmd, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	// a nil channel never fires, leaving the command to run for as long as it takes
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	select {
+	case err = <-done:
+	case <-expired:
+		// closing the session ends the command on the server and makes Wait return
+		session.Signal(ssh.SIGKILL)
+		session.Close()
+		<-done
+		return fmt.Errorf("command '%s' timed out after %s", command, timeout)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return &CommandError{
+			Command:    command,
+			ExitStatus: exitErr.ExitStatus(),
+			Output:     output.Bytes(),
 		}
 	}
 
-	// Wait for sess to finish
-	err = session.Wait()
-	if err != nil {
-		return fmt.Errorf("error in session wait: %w", err)
+	var exitMissingErr *ssh.ExitMissingError
+	if errors.As(err, &exitMissingErr) {
+		return &CommandError{
+			Command:    command,
+			ExitStatus: -1,
+			Output:     output.Bytes(),
+		}
 	}
 
-	return nil
+	return fmt.Errorf("could not run command '%s': %w", command, err)
 }
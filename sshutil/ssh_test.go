@@ -0,0 +1,92 @@
+package sshutil
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResolveJumpHosts(t *testing.T) {
+	config, err := parseSSHConfig(bufio.NewScanner(strings.NewReader(`
+Host bastion
+  HostName bastion.example.com
+  User jump
+
+Host prod-web
+  ProxyJump legacy-bastion
+`)))
+	if err != nil {
+		t.Fatalf("could not parse ssh config: %v", err)
+	}
+
+	server, jumps, err := resolve(config, "deploy@prod-web:2222", "", []string{"bastion", "admin@inner:2200"})
+	if err != nil {
+		t.Fatalf("could not resolve host: %v", err)
+	}
+
+	expectedServer := endpoint{user: "deploy", address: "prod-web:2222"}
+	expectedJumps := []endpoint{
+		{user: "jump", address: "bastion.example.com:22"},
+		{user: "admin", address: "inner:2200"},
+	}
+
+	if !cmp.Equal(expectedServer, server, cmp.AllowUnexported(endpoint{})) {
+		t.Errorf("server does not match expected; Diff below: \n%v", cmp.Diff(expectedServer, server, cmp.AllowUnexported(endpoint{})))
+	}
+
+	if !cmp.Equal(expectedJumps, jumps, cmp.AllowUnexported(endpoint{})) {
+		t.Errorf("jump hosts do not match expected; Diff below: \n%v", cmp.Diff(expectedJumps, jumps, cmp.AllowUnexported(endpoint{})))
+	}
+}
+
+func TestResolveHostname(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolkit_ssh")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config")
+	err = ioutil.WriteFile(configFile, []byte("Host web\n  HostName web.example.com\n  Port 2222\n"), 0644)
+	if err != nil {
+		t.Fatalf("could not write ssh config: %v", err)
+	}
+
+	tests := map[string]string{
+		"deploy@web:2200":       "web.example.com",
+		"web":                   "web.example.com",
+		"deploy@db.example.com": "db.example.com",
+		"10.0.0.5:22":           "10.0.0.5",
+	}
+
+	for destination, expected := range tests {
+		hostname, err := ResolveHostname(destination, Options{ConfigFile: configFile})
+		if err != nil {
+			t.Errorf("%s: could not resolve hostname: %v", destination, err)
+			continue
+		}
+		if hostname != expected {
+			t.Errorf("%s: expected hostname %s; got %s", destination, expected, hostname)
+		}
+	}
+}
+
+func TestCommandScript(t *testing.T) {
+	expected := `set -e
+trap '__toolkit_status=$?; if [ $__toolkit_status -ne 0 ]; then mkdir -p "$HOME/.toolkit" && echo $__toolkit_step > "$HOME/.toolkit/step"; fi' EXIT
+__toolkit_step=0
+cd /opt/app
+__toolkit_step=1
+./app migrate
+`
+
+	script := commandScript([]string{"cd /opt/app", "./app migrate"}, "$HOME/.toolkit/step")
+	if script != expected {
+		t.Errorf("script is not as expected; Diff below: \n%v", cmp.Diff(expected, script))
+	}
+}
@@ -0,0 +1,149 @@
+package sshutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// UploadFile copies a local file to remotePath on the server using the scp protocol over the
+// existing connection, preserving the file's permissions. Progress is written to the progress
+// writer if one is given
+func (c *Client) UploadFile(localPath, remotePath string, progress io.Writer) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return c.Upload(file, info.Size(), info.Mode().Perm(), remotePath, progress)
+}
+
+// Upload writes size bytes from r to remotePath on the server with the file mode given
+// using the scp protocol. Progress is written to the progress writer if one is given
+func (c *Client) Upload(r io.Reader, size int64, mode os.FileMode, remotePath string, progress io.Writer) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("could not establish session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("could not get stdin pipe: %w", err)
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("could not get stdout pipe: %w", err)
+	}
+	acks := bufio.NewReader(stdout)
+
+	// scp in sink mode (-t) receives files over stdin and acknowledges each step over stdout
+	err = session.Start("scp -qt " + shellQuote(remotePath))
+	if err != nil {
+		return fmt.Errorf("could not start remote scp: %w", err)
+	}
+
+	err = readAck(acks)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdin, "C%04o %d %s\n", mode, size, path.Base(remotePath))
+	if err != nil {
+		return fmt.Errorf("could not send file header: %w", err)
+	}
+
+	err = readAck(acks)
+	if err != nil {
+		return err
+	}
+
+	var dst io.Writer = stdin
+	if progress != nil {
+		dst = io.MultiWriter(stdin, &progressWriter{out: progress, total: size, name: path.Base(remotePath)})
+	}
+
+	_, err = io.CopyN(dst, r, size)
+	if err != nil {
+		return fmt.Errorf("could not send file contents: %w", err)
+	}
+
+	// a single null byte signals the end of the file contents
+	_, err = stdin.Write([]byte{0})
+	if err != nil {
+		return fmt.Errorf("could not send file contents: %w", err)
+	}
+
+	err = readAck(acks)
+	if err != nil {
+		return err
+	}
+
+	stdin.Close()
+
+	err = session.Wait()
+	if err != nil {
+		return fmt.Errorf("error in session wait: %w", err)
+	}
+
+	return nil
+}
+
+// readAck reads a single scp protocol response; anything other than a null byte is followed
+// by an error message from the remote
+func readAck(r *bufio.Reader) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("could not read scp response: %w", err)
+	}
+
+	if code == 0 {
+		return nil
+	}
+
+	message, _ := r.ReadString('\n')
+	return fmt.Errorf("remote scp error: %s", strings.TrimSpace(message))
+}
+
+// shellQuote wraps a string in single quotes so it can be safely passed to a remote shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// progressWriter prints the percentage of bytes written out of the total each time it changes
+type progressWriter struct {
+	out     io.Writer
+	name    string
+	total   int64
+	written int64
+	percent int64
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+
+	percent := int64(100)
+	if p.total > 0 {
+		percent = p.written * 100 / p.total
+	}
+
+	if percent != p.percent || p.written == int64(len(b)) {
+		p.percent = percent
+		fmt.Fprintf(p.out, "\ruploading %s: %3d%% (%d/%d bytes)", p.name, percent, p.written, p.total)
+		if p.written >= p.total {
+			fmt.Fprintln(p.out)
+		}
+	}
+
+	return len(b), nil
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"os/exec"
+	"sync"
 	"time"
 )
 
@@ -21,3 +25,66 @@ func ExecuteBashCmd(command string, env []string, workingDir string) ([]byte, er
 	// Execute command and return combined output to user
 	return cmd.CombinedOutput()
 }
+
+// PrintPlan prints a single step of a dry run to stdout in the format "[dry-run] <action>: <detail>"
+// Each step is kept to a single line so that two plans can be compared using diff
+func PrintPlan(action, detail string) {
+	fmt.Printf("[dry-run] %s: %s\n", action, detail)
+}
+
+// PrefixWriter writes each line written to it to an underlying writer with a prefix prepended
+// Partial lines are buffered until they are completed so output from concurrent writers isn't mixed
+type PrefixWriter struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	prefix []byte
+	buffer []byte
+}
+
+// NewPrefixWriter creates a PrefixWriter that writes to out
+func NewPrefixWriter(out io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{
+		out:    out,
+		prefix: []byte(prefix),
+	}
+}
+
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.buffer = append(p.buffer, b...)
+
+	for {
+		index := bytes.IndexByte(p.buffer, '\n')
+		if index == -1 {
+			break
+		}
+
+		line := append(append([]byte{}, p.prefix...), p.buffer[:index+1]...)
+		_, err := p.out.Write(line)
+		if err != nil {
+			return 0, err
+		}
+
+		p.buffer = p.buffer[index+1:]
+	}
+
+	return len(b), nil
+}
+
+// Flush writes out any partial line still buffered
+func (p *PrefixWriter) Flush() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.buffer) == 0 {
+		return nil
+	}
+
+	line := append(append([]byte{}, p.prefix...), p.buffer...)
+	p.buffer = nil
+
+	_, err := p.out.Write(append(line, '\n'))
+	return err
+}